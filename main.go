@@ -19,24 +19,42 @@
 
   获取可用性域
   https://docs.oracle.com/en-us/iaas/api/#/en/identity/20160918/AvailabilityDomain/ListAvailabilityDomains
+  实例配置
+  https://docs.oracle.com/en-us/iaas/api/#/en/iaas/20160918/InstanceConfiguration/
+  实例池
+  https://docs.oracle.com/en-us/iaas/api/#/en/iaas/20160918/InstancePool/
 */
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -45,38 +63,72 @@ import (
 
 	"github.com/oracle/oci-go-sdk/v54/common"
 	"github.com/oracle/oci-go-sdk/v54/core"
+	"github.com/oracle/oci-go-sdk/v54/dns"
 	"github.com/oracle/oci-go-sdk/v54/example/helpers"
 	"github.com/oracle/oci-go-sdk/v54/identity"
+	"github.com/oracle/oci-go-sdk/v54/limits"
 	"gopkg.in/ini.v1"
 )
 
 const (
 	defConfigFilePath = "./oci-help.ini"
 	IPsFilePrefix     = "IPs"
+	imagePinFilePath  = "./oci-help-images.json"
+	templateTagKey    = "oci-help-template" // 创建实例时记录实例模版名称的标签
+	launchTagKey      = "oci-help-launch"   // 同时创建实例时每个请求的唯一标识, 用于确认结果未知的请求是否已创建实例
 )
 
+// 同时创建实例时无法确认请求结果
+var errLaunchUnknown = errors.New("无法确认实例是否已创建")
+
+// 私有IP没有分配公共IP
+var errNoPublicIp = errors.New("未分配公共IP")
+
 var (
 	configFilePath      string
 	provider            common.ConfigurationProvider
 	computeClient       core.ComputeClient
 	networkClient       core.VirtualNetworkClient
 	storageClient       core.BlockstorageClient
+	computeMgmtClient   core.ComputeManagementClient
 	identityClient      identity.IdentityClient
+	limitsClient        limits.LimitsClient
+	dnsClient           dns.DnsClient
 	ctx                 context.Context = context.Background()
 	oracleSections      []*ini.Section
 	oracleSection       *ini.Section
 	oracleSectionName   string
 	oracle              Oracle
 	instanceBaseSection *ini.Section
+	firewallBaseSection *ini.Section
 	instance            Instance
+	instanceSectionName string
 	proxy               string
 	token               string
 	chat_id             string
 	cmd                 string
+	cronLaunch          string
+	cronIP              string
+	cronMode            bool
+	reconcileMode       bool
+	watchdogMode        bool
+	watchdog            watchdogConfig
+	reconcileInterval   int32
+	unlimitedRetryCap   int32                     // 大于 0 时, retry=-1 (无限重试) 的模版最多重试的次数
+	reconcileDrift      = make(map[string]string) // 每个账号上一次报告的偏差, 偏差变化时才发送消息提醒
+	exportFormats       string
+	sshIdentityFile     string
+	inventoryPath       string          // 当前导出文件路径, 路径变化时清空已导出的实例
+	inventoryHosts      []inventoryHost // 多个账号导出到同一组文件时, 已导出的实例
 	sendMessageUrl      string
 	editMessageUrl      string
 	EACH                bool
 	availabilityDomains []identity.AvailabilityDomain
+	errorPolicyRules    []errorPolicyRule
+	growJobs            = make(map[string]*growJob)
+	growJobsMutex       sync.Mutex
+	dryRun              bool
+	networkPlan         []string // dry run 模式下将要创建或修改的网络资源
 )
 
 type Oracle struct {
@@ -107,6 +159,205 @@ type Instance struct {
 	CloudInit              string  `ini:"cloud-init"`
 	MinTime                int32   `ini:"minTime"`
 	MaxTime                int32   `ini:"maxTime"`
+	RetryStrategy          string  `ini:"retryStrategy"`
+	BackoffMaxTime         int32   `ini:"backoffMaxTime"`
+	ReleaseTimes           string  `ini:"releaseTimes"`
+	ReleaseWindow          int32   `ini:"releaseWindow"`
+	Parallel               bool    `ini:"parallel"`
+	ShapeLadder            string  `ini:"shapeLadder"`
+	LadderUpsize           bool    `ini:"ladderUpsize"`
+	GrowTo                 string  `ini:"growTo"`
+	GrowInterval           int32   `ini:"growInterval"`
+	FreeTierGuard          string  `ini:"freeTierGuard"`
+	AllowPaid              bool    `ini:"allowPaid"`
+	LimitName              string  `ini:"limitName"`
+	DryRun                 bool    `ini:"dryRun"`
+	LaunchWindow           string  `ini:"launchWindow"`
+	LaunchCron             string  `ini:"launchCron"`
+	StartAt                string  `ini:"startAt"`
+	ImageId                string  `ini:"imageId"`
+	ImageName              string  `ini:"imageName"`
+	ImageSource            string  `ini:"imageSource"`
+	ImagePin               bool    `ini:"imagePin"`
+	Preemptible            bool    `ini:"preemptible"`
+	PreserveBootVolume     bool    `ini:"preserveBootVolume"`
+	CapacityReservationId  string  `ini:"capacityReservationId"`
+	DedicatedVmHostId      string  `ini:"dedicatedVmHostId"`
+	FaultDomain            string  `ini:"faultDomain"`
+	BootVolumeType         string  `ini:"bootVolumeType"`
+	NetworkType            string  `ini:"networkType"`
+	Firmware               string  `ini:"firmware"`
+	PvEncryptionInTransit  string  `ini:"pvEncryptionInTransit"`
+	ShieldedInstance       bool    `ini:"shieldedInstance"`
+	SecureBoot             bool    `ini:"secureBoot"`
+	MeasuredBoot           bool    `ini:"measuredBoot"`
+	TrustedPlatformModule  bool    `ini:"trustedPlatformModule"`
+	BootVolumeVpusPerGB    int64   `ini:"bootVolumeVpusPerGB"`
+	Source                 string  `ini:"source"`
+	BootVolume             string  `ini:"bootVolume"`
+	PoolSize               int32   `ini:"poolSize"`
+	DesiredNames           string  `ini:"desiredNames"`
+	ReconcileStart         bool    `ini:"reconcileStart"`
+	Readiness              string  `ini:"readiness"`
+	ReadinessTimeout       int32   `ini:"readinessTimeout"`
+	OnSuccess              string  `ini:"on_success"`
+	OnFailure              string  `ini:"on_failure"`
+	OnIpChange             string  `ini:"on_ip_change"`
+	OnTerminate            string  `ini:"on_terminate"`
+	HookTimeout            int32   `ini:"hookTimeout"`
+	DnsProvider            string  `ini:"dnsProvider"`
+	DnsName                string  `ini:"dnsName"`
+	DnsZone                string  `ini:"dnsZone"`
+	DnsTtl                 int     `ini:"dnsTtl"`
+	DnsIpv6                bool    `ini:"dnsIpv6"`
+	CloudflareToken        string  `ini:"cloudflareToken"`
+	DnsServer              string  `ini:"dnsServer"`
+	TsigKey                string  `ini:"tsigKey"`
+	TsigAlgorithm          string  `ini:"tsigAlgorithm"`
+	Firewall               string  `ini:"firewall"`
+	FirewallTarget         string  `ini:"firewallTarget"`
+	NsgName                string  `ini:"nsgName"`
+	NsgIds                 string  `ini:"nsgIds"`
+	VcnCidr                string  `ini:"vcnCidr"`
+	VcnDnsLabel            string  `ini:"vcnDnsLabel"`
+	SubnetCidr             string  `ini:"subnetCidr"`
+	SubnetDnsLabel         string  `ini:"subnetDnsLabel"`
+	SubnetType             string  `ini:"subnetType"`
+	SubnetScope            string  `ini:"subnetScope"`
+}
+
+// Always Free 资源限额
+// https://docs.oracle.com/en-us/iaas/Content/FreeTier/freetier_topic-Always_Free_Resources.htm
+const (
+	freeTierA1Ocpus       float32 = 4
+	freeTierA1MemoryInGBs float32 = 24
+	freeTierMicroCount    int32   = 2
+	freeTierStorageInGBs  int64   = 200
+)
+
+// 账号中已使用的 Always Free 资源
+type freeTierUsage struct {
+	A1Ocpus       float32
+	A1MemoryInGBs float32
+	MicroCount    int32
+	StorageInGBs  int64 // 引导卷和块存储卷总大小
+}
+
+// 弹性实例配置档位
+type shapeRung struct {
+	Ocpus       float32
+	MemoryInGBs float32
+}
+
+func (r shapeRung) String() string {
+	return fmt.Sprintf("%g/%g", r.Ocpus, r.MemoryInGBs)
+}
+
+// 实例就绪检查
+type readinessProbe struct {
+	Kind   string // running / tcp / ssh / http / cloud-init
+	Target string // tcp 和 ssh 为端口, http 为 URL ({ip} 替换为实例公共IP)
+}
+
+// 导出到 Ansible 清单、SSH 配置、CSV 和 JSON 的实例信息
+type inventoryHost struct {
+	Account            string `json:"account"`
+	Region             string `json:"region"`
+	Name               string `json:"name"`
+	InstanceId         string `json:"instance_id"`
+	State              string `json:"state"`
+	AvailabilityDomain string `json:"availability_domain"`
+	Shape              string `json:"shape"`
+	OperatingSystem    string `json:"operating_system"`
+	PublicIp           string `json:"public_ip"`
+	PrivateIp          string `json:"private_ip"`
+	User               string `json:"ssh_user"`
+	Alias              string `json:"-"` // Ansible 和 SSH 配置中的主机名
+}
+
+// 公共IP自动更换配置
+type watchdogConfig struct {
+	Interval  int32  `ini:"interval"`  // 检查间隔 (秒)
+	Probes    string `ini:"probes"`    // 检查方式, tcp:端口 或检测接口 URL ({ip} 替换为公共IP), 多个用英文逗号分隔
+	Failures  int32  `ini:"failures"`  // 连续失败次数达到该值时更换公共IP
+	Cooldown  int32  `ini:"cooldown"`  // 同一实例两次更换的最小间隔 (秒)
+	MaxPerDay int32  `ini:"maxPerDay"` // 同一实例每天最多更换次数
+	Instances string `ini:"instances"` // 检查的实例名称, 多个用英文逗号分隔, 留空检查所有实例
+}
+
+// 实例公共IP检查状态
+type watchdogState struct {
+	failures  int32
+	rotatedAt time.Time
+	day       string // 更换次数的统计日期
+	rotations int32
+}
+
+// 钩子事件
+const (
+	hookOnSuccess   = "on_success"
+	hookOnFailure   = "on_failure"
+	hookOnIpChange  = "on_ip_change"
+	hookOnTerminate = "on_terminate"
+)
+
+// 传递给钩子的事件信息
+type hookEvent struct {
+	Event              string   `json:"event"`
+	Account            string   `json:"account"`
+	Region             string   `json:"region"`
+	Template           string   `json:"template,omitempty"`
+	InstanceId         string   `json:"instance_id,omitempty"`
+	Name               string   `json:"name,omitempty"`
+	IPs                []string `json:"ips,omitempty"`
+	AvailabilityDomain string   `json:"availability_domain,omitempty"`
+	Shape              string   `json:"shape,omitempty"`
+	Message            string   `json:"message,omitempty"`
+}
+
+const (
+	errorActionRetry         = "retry"          // 继续重试
+	errorActionSkipAD        = "skip_ad"        // 跳过当前可用性域 (设置了可用性域时放弃当前实例)
+	errorActionAbortTemplate = "abort_template" // 停止当前实例模版
+	errorActionAbortAccount  = "abort_account"  // 停止当前账号的所有实例模版
+)
+
+// 错误策略规则, 按状态码、错误码和错误信息匹配创建实例时返回的错误
+type errorPolicyRule struct {
+	name      string
+	minStatus int // 0 表示匹配任意状态码
+	maxStatus int
+	code      string         // 空字符串表示匹配任意错误码
+	message   *regexp.Regexp // nil 表示匹配任意错误信息
+	action    string
+	notify    bool // 是否发送 Telegram 消息提醒
+}
+
+// 默认错误策略, 在配置文件中的规则之后匹配
+// API Errors: https://docs.cloud.oracle.com/Content/API/References/apierrors.htm
+var defaultErrorPolicyRules = []errorPolicyRule{
+	{name: "TooManyRequests", minStatus: 429, maxStatus: 429, action: errorActionRetry},
+	{name: "IncorrectState", minStatus: 409, maxStatus: 409, code: "IncorrectState", action: errorActionRetry},
+	{name: "400-405", minStatus: 400, maxStatus: 405, action: errorActionSkipAD},
+	{name: "409", minStatus: 409, maxStatus: 409, action: errorActionSkipAD},
+	{name: "412-413", minStatus: 412, maxStatus: 413, action: errorActionSkipAD},
+	{name: "422", minStatus: 422, maxStatus: 422, action: errorActionSkipAD},
+	{name: "431", minStatus: 431, maxStatus: 431, action: errorActionSkipAD},
+	{name: "501", minStatus: 501, maxStatus: 501, action: errorActionSkipAD},
+}
+
+// 后台升级实例任务, 定时尝试将实例升级到目标配置直到成功
+type growJob struct {
+	account    string
+	client     core.ComputeClient
+	instanceId string
+	name       string
+	target     shapeRung
+	interval   time.Duration
+	stop       chan struct{}
+	mutex      sync.Mutex // 保护 attempts 和 status, 查看实例详细信息时会同时读取
+	attempts   int32
+	status     string
 }
 
 type Message struct {
@@ -122,6 +373,10 @@ type Result struct {
 func main() {
 	flag.StringVar(&configFilePath, "config", defConfigFilePath, "配置文件路径")
 	flag.StringVar(&configFilePath, "c", defConfigFilePath, "配置文件路径")
+	flag.BoolVar(&dryRun, "dry-run", false, "只显示将要创建的实例和网络资源, 不实际创建")
+	flag.BoolVar(&cronMode, "cron", false, "按照配置文件中的 cronLaunch 和 cronIP 定时执行任务")
+	flag.BoolVar(&reconcileMode, "reconcile", false, "按照实例模版中的 desiredNames 持续检查并创建缺少的实例")
+	flag.BoolVar(&watchdogMode, "watchdog", false, "按照配置文件中的 WATCHDOG 持续检查实例公共IP, 无法连接时自动更换")
 	flag.Parse()
 
 	cfg, err := ini.Load(configFilePath)
@@ -131,6 +386,11 @@ func main() {
 	token = defSec.Key("token").Value()
 	chat_id = defSec.Key("chat_id").Value()
 	cmd = defSec.Key("cmd").Value()
+	cronLaunch = defSec.Key("cronLaunch").Value()
+	cronIP = defSec.Key("cronIP").Value()
+	reconcileInterval = int32(defSec.Key("reconcileInterval").MustInt(300))
+	exportFormats = defSec.Key("exportFormats").Value()
+	sshIdentityFile = defSec.Key("sshIdentityFile").Value()
 	if defSec.HasKey("EACH") {
 		EACH, _ = defSec.Key("EACH").Bool()
 	} else {
@@ -159,7 +419,31 @@ func main() {
 		return
 	}
 	instanceBaseSection = cfg.Section("INSTANCE")
+	firewallBaseSection = cfg.Section("FIREWALL")
+	errorPolicyRules, err = loadErrorPolicy(cfg.Section("ERRORPOLICY"))
+	if err != nil {
+		printlnErr("解析错误策略失败", err.Error())
+		return
+	}
+
+	if cronMode {
+		runScheduler()
+		return
+	}
+	err = cfg.Section("WATCHDOG").MapTo(&watchdog)
+	if err != nil {
+		printlnErr("解析 WATCHDOG 参数失败", err.Error())
+		return
+	}
 
+	if reconcileMode {
+		runReconciler(oracleSections)
+		return
+	}
+	if watchdogMode {
+		runWatchdog(oracleSections)
+		return
+	}
 	listOracleAccount()
 }
 
@@ -192,6 +476,14 @@ func listOracleAccount() {
 				multiBatchListInstancesIp()
 				listOracleAccount()
 				return
+			} else if strings.EqualFold(input, "reconcile") {
+				runReconciler(oracleSections)
+				listOracleAccount()
+				return
+			} else if strings.EqualFold(input, "watchdog") {
+				runWatchdog(oracleSections)
+				listOracleAccount()
+				return
 			}
 			index, _ = strconv.Atoi(input)
 			if 0 < index && index <= len(oracleSections) {
@@ -256,12 +548,30 @@ func initVar(oracleSec *ini.Section) (err error) {
 		return
 	}
 	setProxyOrNot(&storageClient.BaseClient)
+	computeMgmtClient, err = core.NewComputeManagementClientWithConfigurationProvider(provider)
+	if err != nil {
+		printlnErr("创建 ComputeManagementClient 失败", err.Error())
+		return
+	}
+	setProxyOrNot(&computeMgmtClient.BaseClient)
 	identityClient, err = identity.NewIdentityClientWithConfigurationProvider(provider)
 	if err != nil {
 		printlnErr("创建 IdentityClient 失败", err.Error())
 		return
 	}
 	setProxyOrNot(&identityClient.BaseClient)
+	limitsClient, err = limits.NewLimitsClientWithConfigurationProvider(provider)
+	if err != nil {
+		printlnErr("创建 LimitsClient 失败", err.Error())
+		return
+	}
+	setProxyOrNot(&limitsClient.BaseClient)
+	dnsClient, err = dns.NewDnsClientWithConfigurationProvider(provider)
+	if err != nil {
+		printlnErr("创建 DnsClient 失败", err.Error())
+		return
+	}
+	setProxyOrNot(&dnsClient.BaseClient)
 	return
 }
 
@@ -270,6 +580,10 @@ func showMainMenu() {
 	fmt.Printf("\033[1;36m%s\033[0m %s\n", "1.", "查看实例")
 	fmt.Printf("\033[1;36m%s\033[0m %s\n", "2.", "创建实例")
 	fmt.Printf("\033[1;36m%s\033[0m %s\n", "3.", "管理引导卷")
+	fmt.Printf("\033[1;36m%s\033[0m %s\n", "4.", "管理自定义镜像")
+	fmt.Printf("\033[1;36m%s\033[0m %s\n", "5.", "管理实例池")
+	fmt.Printf("\033[1;36m%s\033[0m %s\n", "6.", "管理预留公共IP")
+	fmt.Printf("\033[1;36m%s\033[0m %s\n", "7.", "管理防火墙规则")
 	fmt.Print("\n请输入序号进入相关操作: ")
 	var input string
 	var num int
@@ -283,6 +597,14 @@ func showMainMenu() {
 		batchListInstancesIp(IPsFilePath, oracleSection)
 		showMainMenu()
 		return
+	} else if strings.EqualFold(input, "reconcile") {
+		runReconciler([]*ini.Section{oracleSection})
+		showMainMenu()
+		return
+	} else if strings.EqualFold(input, "watchdog") {
+		runWatchdog([]*ini.Section{oracleSection})
+		showMainMenu()
+		return
 	}
 	num, _ = strconv.Atoi(input)
 	switch num {
@@ -292,6 +614,14 @@ func showMainMenu() {
 		listLaunchInstanceTemplates()
 	case 3:
 		listBootVolumes()
+	case 4:
+		listCustomImages()
+	case 5:
+		listInstancePools()
+	case 6:
+		listReservedIps()
+	case 7:
+		listFirewalls()
 	default:
 		if len(oracleSections) > 1 {
 			listOracleAccount()
@@ -428,6 +758,7 @@ func listInstances() {
 						fmt.Printf("\033[1;31m实例 %s 终止失败.\033[0m %s\n", *ins.DisplayName, err.Error())
 					} else {
 						fmt.Printf("\033[1;32m实例 %s 终止成功.\033[0m\n", *ins.DisplayName)
+						runInstanceHook(ins, hookOnTerminate, nil)
 					}
 				}
 			} else {
@@ -496,9 +827,17 @@ func instanceDetails(instanceId *string) {
 		for _, value := range instance.AgentConfig.PluginsConfig {
 			fmt.Printf("%s: %s\n", *value.Name, value.DesiredState)
 		}
+		growJobsMutex.Lock()
+		job := growJobs[*instance.Id]
+		growJobsMutex.Unlock()
+		if job != nil {
+			attempts, status := job.progress()
+			fmt.Printf("自动升级: %s, 尝试次数: %d, 状态: %s\n", job.target, attempts, status)
+		}
 		fmt.Println("--------------------")
 		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s   4: %s   5: %s\033[0m\n", "启动", "停止", "重启", "终止", "更换公共IP")
-		fmt.Printf("\033[1;32m6: %s   7: %s   8: %s\033[0m\n", "升级/降级", "修改名称", "Oracle Cloud Agent 插件配置")
+		fmt.Printf("\033[1;32m6: %s   7: %s   8: %s   9: %s\033[0m\n", "升级/降级", "修改名称", "Oracle Cloud Agent 插件配置", "自动升级")
+		fmt.Printf("\033[1;32m10: %s\033[0m\n", "创建自定义镜像")
 		var input string
 		var num int
 		fmt.Print("\n请输入需要执行操作的序号: ")
@@ -542,6 +881,7 @@ func instanceDetails(instanceId *string) {
 					fmt.Printf("\033[1;31m终止实例失败.\033[0m %s\n", err.Error())
 				} else {
 					fmt.Printf("\033[1;32m正在终止实例, 请稍后查看实例状态\033[0m\n")
+					runInstanceHook(instance, hookOnTerminate, nil)
 				}
 				time.Sleep(1 * time.Second)
 			}
@@ -551,15 +891,28 @@ func instanceDetails(instanceId *string) {
 				fmt.Printf("\033[1;31m实例已终止或获取实例VNIC失败，请稍后重试.\033[0m\n")
 				break
 			}
-			fmt.Printf("将删除当前公共IP并创建一个新的公共IP。确定更换实例公共IP？(输入 y 并回车): ")
+			reservedIp, ok := selectReservedIp()
+			if !ok {
+				break
+			}
+			if reservedIp != nil {
+				fmt.Printf("将删除当前临时公共IP并分配预留公共IP %s。确定更换实例公共IP？(输入 y 并回车): ", *reservedIp.IpAddress)
+			} else {
+				fmt.Printf("将删除当前公共IP并创建一个新的公共IP。确定更换实例公共IP？(输入 y 并回车): ")
+			}
 			var input string
 			fmt.Scanln(&input)
 			if strings.EqualFold(input, "y") {
-				publicIp, err := changePublicIp(vnics)
+				var reservedIpId *string
+				if reservedIp != nil {
+					reservedIpId = reservedIp.Id
+				}
+				publicIp, err := changePublicIp(vnics, reservedIpId)
 				if err != nil {
 					fmt.Printf("\033[1;31m更换实例公共IP失败.\033[0m %s\n", err.Error())
 				} else {
 					fmt.Printf("\033[1;32m更换实例公共IP成功, 实例公共IP: \033[0m%s\n", *publicIp.IpAddress)
+					runInstanceHook(instance, hookOnIpChange, []string{*publicIp.IpAddress})
 				}
 				time.Sleep(1 * time.Second)
 			}
@@ -624,6 +977,53 @@ func instanceDetails(instanceId *string) {
 			}
 			time.Sleep(1 * time.Second)
 
+		case 9:
+			if job != nil {
+				fmt.Printf("确定停止自动升级？(输入 y 并回车): ")
+				var input string
+				fmt.Scanln(&input)
+				if strings.EqualFold(input, "y") {
+					stopGrowJob(*instance.Id)
+					fmt.Printf("\033[1;32m已停止自动升级.\033[0m\n")
+				}
+				time.Sleep(1 * time.Second)
+				break
+			}
+			var input string
+			fmt.Printf("自动升级实例, 请输入目标CPU个数: ")
+			fmt.Scanln(&input)
+			ocpus, _ := strconv.ParseFloat(input, 32)
+			input = ""
+			fmt.Printf("自动升级实例, 请输入目标内存大小: ")
+			fmt.Scanln(&input)
+			memoryInGBs, _ := strconv.ParseFloat(input, 32)
+			input = ""
+			fmt.Printf("自动升级实例, 请输入尝试间隔(秒, 默认300): ")
+			fmt.Scanln(&input)
+			interval, _ := strconv.Atoi(input)
+			if ocpus <= 0 || memoryInGBs <= 0 {
+				fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+			} else {
+				startGrowJob(instance, shapeRung{Ocpus: float32(ocpus), MemoryInGBs: float32(memoryInGBs)}, time.Duration(interval)*time.Second)
+				fmt.Printf("\033[1;32m已开始在后台自动升级实例.\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
+
+		case 10:
+			fmt.Printf("创建自定义镜像时实例将会重启, 请为自定义镜像输入一个名称 (回车取消): ")
+			var input string
+			fmt.Scanln(&input)
+			if input != "" {
+				fmt.Println("正在创建自定义镜像...")
+				image, err := createImage(instance.Id, input)
+				if err != nil {
+					fmt.Printf("\033[1;31m创建自定义镜像失败.\033[0m %s\n", err.Error())
+				} else {
+					fmt.Printf("\033[1;32m正在创建自定义镜像 %s, 请稍后在自定义镜像中查看状态\033[0m\n", *image.DisplayName)
+				}
+				time.Sleep(1 * time.Second)
+			}
+
 		default:
 			listInstances()
 			return
@@ -723,7 +1123,7 @@ func bootvolumeDetails(bootVolumeId *string) {
 		fmt.Printf("性能: %s\n", performance)
 		fmt.Printf("附加的实例: %s\n", strings.Join(attachIns, ","))
 		fmt.Println("--------------------")
-		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s   4: %s\033[0m\n", "修改性能", "修改大小", "分离引导卷", "终止引导卷")
+		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s   4: %s   5: %s\033[0m\n", "修改性能", "修改大小", "分离引导卷", "终止引导卷", "使用该引导卷创建实例")
 		var input string
 		var num int
 		fmt.Print("\n请输入需要执行操作的序号: ")
@@ -802,6 +1202,9 @@ func bootvolumeDetails(bootVolumeId *string) {
 			}
 			time.Sleep(1 * time.Second)
 
+		case 5:
+			relaunchFromBootVolume(bootVolume)
+
 		default:
 			listBootVolumes()
 			return
@@ -809,1013 +1212,5922 @@ func bootvolumeDetails(bootVolumeId *string) {
 	}
 }
 
-func listLaunchInstanceTemplates() {
-	var instanceSections []*ini.Section
-	instanceSections = append(instanceSections, instanceBaseSection.ChildSections()...)
-	instanceSections = append(instanceSections, oracleSection.ChildSections()...)
-	if len(instanceSections) == 0 {
-		fmt.Printf("\033[1;31m未找到实例模版, 回车返回上一级菜单.\033[0m")
+func listCustomImages() {
+	fmt.Println("正在获取自定义镜像...")
+	images, err := getCustomImages()
+	if err != nil {
+		printlnErr("获取失败, 回车返回上一级菜单.", err.Error())
 		fmt.Scanln()
 		showMainMenu()
 		return
 	}
 
+	fmt.Printf("\n\033[1;32m自定义镜像\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "名称", "状态　　", "系统")
+	for i, image := range images {
+		var system string
+		if image.OperatingSystem != nil && image.OperatingSystemVersion != nil {
+			system = *image.OperatingSystem + " " + *image.OperatingSystemVersion
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, *image.DisplayName, getImageState(image.LifecycleState), system)
+	}
+	w.Flush()
+	fmt.Println("--------------------")
+	fmt.Printf("\n\033[1;32ma: %s\033[0m\n", "从对象存储导入镜像")
+	var input string
+	var index int
 	for {
-		fmt.Printf("\n\033[1;32m选择对应的实例模版开始创建实例\033[0m \n(当前账号: %s)\n\n", oracleSectionName)
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 4, 8, 1, '\t', 0)
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "配置", "CPU个数", "内存(GB)")
-		for i, instanceSec := range instanceSections {
-			cpu := instanceSec.Key("cpus").Value()
-			if cpu == "" {
-				cpu = "-"
-			}
-			memory := instanceSec.Key("memoryInGBs").Value()
-			if memory == "" {
-				memory = "-"
-			}
-			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, instanceSec.Key("shape").Value(), cpu, memory)
+		fmt.Print("请输入序号查看自定义镜像详细信息: ")
+		_, err := fmt.Scanln(&input)
+		if err != nil {
+			showMainMenu()
+			return
 		}
-		w.Flush()
-		fmt.Printf("\n")
-		var input string
-		var index int
-		for {
-			fmt.Print("请输入需要创建的实例的序号: ")
-			_, err := fmt.Scanln(&input)
-			if err != nil {
-				showMainMenu()
-				return
+		if input == "a" {
+			var name, uri string
+			fmt.Printf("请输入镜像名称: ")
+			fmt.Scanln(&name)
+			fmt.Printf("请输入对象存储URL (预验证请求URL): ")
+			fmt.Scanln(&uri)
+			if name == "" || uri == "" {
+				fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+				continue
 			}
-			index, _ = strconv.Atoi(input)
-			if 0 < index && index <= len(instanceSections) {
-				break
+			fmt.Println("正在导入镜像...")
+			_, err := importImage(name, uri)
+			if err != nil {
+				fmt.Printf("\033[1;31m导入镜像失败.\033[0m %s\n", err.Error())
 			} else {
-				input = ""
-				index = 0
-				fmt.Printf("\033[1;31m错误! 请输入正确的序号\033[0m\n")
+				fmt.Printf("\033[1;32m正在导入镜像, 请稍后查看镜像状态\033[0m\n")
 			}
+			time.Sleep(1 * time.Second)
+			listCustomImages()
+			return
 		}
-
-		instanceSection := instanceSections[index-1]
-		instance = Instance{}
-		err := instanceSection.MapTo(&instance)
-		if err != nil {
-			printlnErr("解析实例模版参数失败", err.Error())
-			continue
+		index, _ = strconv.Atoi(input)
+		if 0 < index && index <= len(images) {
+			break
+		} else {
+			input = ""
+			index = 0
+			fmt.Printf("\033[1;31m错误! 请输入正确的序号\033[0m\n")
 		}
-
-		LaunchInstances(availabilityDomains)
 	}
-
+	customImageDetails(images[index-1].Id)
 }
 
-func multiBatchLaunchInstances() {
-	IPsFilePath := IPsFilePrefix + "-" + time.Now().Format("2006-01-02-150405.txt")
-	for _, sec := range oracleSections {
-		var err error
-		err = initVar(sec)
+func customImageDetails(imageId *string) {
+	for {
+		fmt.Println("正在获取自定义镜像详细信息...")
+		resp, err := computeClient.GetImage(ctx, core.GetImageRequest{
+			ImageId:         imageId,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
 		if err != nil {
-			continue
+			fmt.Printf("\033[1;31m获取自定义镜像详细信息失败, 回车返回上一级菜单.\033[0m")
+			fmt.Scanln()
+			listCustomImages()
+			return
 		}
-		// 获取可用性域
-		availabilityDomains, err = ListAvailabilityDomains()
-		if err != nil {
-			printlnErr("获取可用性域失败", err.Error())
-			continue
+		image := resp.Image
+
+		fmt.Printf("\n\033[1;32m自定义镜像详细信息\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+		fmt.Println("--------------------")
+		fmt.Printf("名称: %s\n", *image.DisplayName)
+		fmt.Printf("OCID: %s\n", *image.Id)
+		fmt.Printf("状态: %s\n", getImageState(image.LifecycleState))
+		if image.OperatingSystem != nil && image.OperatingSystemVersion != nil {
+			fmt.Printf("系统: %s %s\n", *image.OperatingSystem, *image.OperatingSystemVersion)
+		}
+		if image.SizeInMBs != nil {
+			fmt.Printf("大小(GB): %g\n", math.Round(float64(*image.SizeInMBs)/float64(1024)))
+		}
+		fmt.Printf("创建时间: %s\n", image.TimeCreated.Local().Format("2006-01-02 15:04:05"))
+		fmt.Println("--------------------")
+		fmt.Printf("在实例模版中设置 imageId=%s 使用该镜像创建实例\n", *image.Id)
+		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s\033[0m\n", "修改名称", "导出到对象存储", "删除")
+		var input string
+		var num int
+		fmt.Print("\n请输入需要执行操作的序号: ")
+		fmt.Scanln(&input)
+		num, _ = strconv.Atoi(input)
+		switch num {
+		case 1:
+			fmt.Printf("请为自定义镜像输入一个新的名称: ")
+			var input string
+			fmt.Scanln(&input)
+			_, err := updateImageName(image.Id, input)
+			if err != nil {
+				fmt.Printf("\033[1;31m修改名称失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m修改名称成功.\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
+
+		case 2:
+			fmt.Printf("请输入对象存储URL (具有写入权限的预验证请求URL): ")
+			var input string
+			fmt.Scanln(&input)
+			if input == "" {
+				fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+				break
+			}
+			_, err := exportImage(image.Id, input)
+			if err != nil {
+				fmt.Printf("\033[1;31m导出镜像失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m正在导出镜像, 请稍后查看镜像状态\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
+
+		case 3:
+			fmt.Printf("确定删除自定义镜像？(输入 y 并回车): ")
+			var input string
+			fmt.Scanln(&input)
+			if strings.EqualFold(input, "y") {
+				err := deleteImage(image.Id)
+				if err != nil {
+					fmt.Printf("\033[1;31m删除自定义镜像失败.\033[0m %s\n", err.Error())
+				} else {
+					fmt.Printf("\033[1;32m删除自定义镜像成功.\033[0m\n")
+					time.Sleep(1 * time.Second)
+					listCustomImages()
+					return
+				}
+				time.Sleep(1 * time.Second)
+			}
+
+		default:
+			listCustomImages()
+			return
 		}
-		batchLaunchInstances(sec)
-		batchListInstancesIp(IPsFilePath, sec)
-		command(cmd)
-		sleepRandomSecond(5, 5)
 	}
 }
 
-func batchLaunchInstances(oracleSec *ini.Section) {
-	var instanceSections []*ini.Section
-	instanceSections = append(instanceSections, instanceBaseSection.ChildSections()...)
-	instanceSections = append(instanceSections, oracleSec.ChildSections()...)
-	if len(instanceSections) == 0 {
+func listInstancePools() {
+	fmt.Println("正在获取实例池...")
+	pools, err := getInstancePools()
+	if err != nil {
+		printlnErr("获取失败, 回车返回上一级菜单.", err.Error())
+		fmt.Scanln()
+		showMainMenu()
 		return
 	}
 
-	printf("\033[1;36m[%s] 开始创建\033[0m\n", oracleSectionName)
-	var SUM, NUM int32 = 0, 0
-	sendMessage(fmt.Sprintf("[%s]", oracleSectionName), "开始创建")
-
-	for _, instanceSec := range instanceSections {
-		instance = Instance{}
-		err := instanceSec.MapTo(&instance)
+	fmt.Printf("\n\033[1;32m实例池\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "名称", "状态　　", "实例数量")
+	for i, pool := range pools {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t\n", i+1, *pool.DisplayName, getInstancePoolState(core.InstancePoolLifecycleStateEnum(pool.LifecycleState)), *pool.Size)
+	}
+	w.Flush()
+	fmt.Println("--------------------")
+	fmt.Printf("\n\033[1;32ma: %s\033[0m\n", "使用实例模版创建实例池")
+	var input string
+	var index int
+	for {
+		fmt.Print("请输入序号查看实例池详细信息: ")
+		_, err := fmt.Scanln(&input)
 		if err != nil {
-			printlnErr("解析实例模版参数失败", err.Error())
-			continue
+			showMainMenu()
+			return
 		}
+		if input == "a" {
+			createInstancePoolFromTemplate()
+			time.Sleep(1 * time.Second)
+			listInstancePools()
+			return
+		}
+		index, _ = strconv.Atoi(input)
+		if 0 < index && index <= len(pools) {
+			break
+		} else {
+			input = ""
+			index = 0
+			fmt.Printf("\033[1;31m错误! 请输入正确的序号\033[0m\n")
+		}
+	}
+	instancePoolDetails(pools[index-1].Id)
+}
 
-		sum, num := LaunchInstances(availabilityDomains)
+func instancePoolDetails(poolId *string) {
+	for {
+		fmt.Println("正在获取实例池详细信息...")
+		resp, err := computeMgmtClient.GetInstancePool(ctx, core.GetInstancePoolRequest{
+			InstancePoolId:  poolId,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			fmt.Printf("\033[1;31m获取实例池详细信息失败, 回车返回上一级菜单.\033[0m")
+			fmt.Scanln()
+			listInstancePools()
+			return
+		}
+		pool := resp.InstancePool
+		members, err := listInstancePoolInstances(pool.Id)
+		if err != nil {
+			printlnErr("获取实例池中的实例失败", err.Error())
+		}
 
-		SUM = SUM + sum
-		NUM = NUM + num
+		fmt.Printf("\n\033[1;32m实例池详细信息\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+		fmt.Println("--------------------")
+		fmt.Printf("名称: %s\n", *pool.DisplayName)
+		fmt.Printf("OCID: %s\n", *pool.Id)
+		fmt.Printf("状态: %s\n", getInstancePoolState(pool.LifecycleState))
+		fmt.Printf("实例数量: %d\n", *pool.Size)
+		var adNames []string
+		for _, placement := range pool.PlacementConfigurations {
+			adNames = append(adNames, adShortName(*placement.AvailabilityDomain))
+		}
+		fmt.Printf("可用性域: %s\n", strings.Join(adNames, ", "))
+		fmt.Printf("创建时间: %s\n", pool.TimeCreated.Local().Format("2006-01-02 15:04:05"))
+		fmt.Println("--------------------")
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "名称", "状态　　", "公共IP")
+		for i, member := range members {
+			state := core.InstanceLifecycleStateEnum(strings.ToUpper(*member.State))
+			ip := "-"
+			if state == core.InstanceLifecycleStateRunning {
+				ips, err := getInstancePublicIps(member.Id)
+				if err == nil && len(ips) > 0 {
+					ip = strings.Join(ips, ",")
+				}
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, *member.DisplayName, getInstanceState(state), ip)
+		}
+		w.Flush()
+		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s\033[0m\n", "修改实例数量", "分离实例", "终止实例池")
+		var input string
+		var num int
+		fmt.Print("\n请输入需要执行操作的序号: ")
+		fmt.Scanln(&input)
+		num, _ = strconv.Atoi(input)
+		switch num {
+		case 1:
+			fmt.Printf("请输入实例数量: ")
+			var input string
+			fmt.Scanln(&input)
+			size, err := strconv.Atoi(input)
+			if err != nil || size < 0 {
+				fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+				break
+			}
+			_, err = updateInstancePoolSize(pool.Id, size)
+			if err != nil {
+				fmt.Printf("\033[1;31m修改实例数量失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m修改实例数量成功, 实例池将自动创建或终止实例\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
 
-	}
-	printf("\033[1;36m[%s] 结束创建。创建实例总数: %d, 成功 %d , 失败 %d\033[0m\n", oracleSectionName, SUM, NUM, SUM-NUM)
-	text := fmt.Sprintf("结束创建。创建实例总数: %d, 成功 %d , 失败 %d", SUM, NUM, SUM-NUM)
-	sendMessage(fmt.Sprintf("[%s]", oracleSectionName), text)
-}
+		case 2:
+			fmt.Printf("请输入需要分离的实例的序号: ")
+			var input string
+			fmt.Scanln(&input)
+			index, _ := strconv.Atoi(input)
+			if index <= 0 || index > len(members) {
+				fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+				break
+			}
+			fmt.Printf("分离后是否终止该实例？(输入 y 并回车终止实例): ")
+			var terminate string
+			fmt.Scanln(&terminate)
+			fmt.Printf("是否保持实例池的实例数量, 自动创建新实例？(输入 y 并回车创建新实例): ")
+			var replace string
+			fmt.Scanln(&replace)
+			err := detachInstancePoolInstance(pool.Id, members[index-1].Id, strings.EqualFold(terminate, "y"), !strings.EqualFold(replace, "y"))
+			if err != nil {
+				fmt.Printf("\033[1;31m分离实例失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m正在分离实例, 请稍后查看实例池信息\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
 
-func multiBatchListInstancesIp() {
-	IPsFilePath := IPsFilePrefix + "-" + time.Now().Format("2006-01-02-150405.txt")
-	_, err := os.Stat(IPsFilePath)
-	if err != nil && os.IsNotExist(err) {
-		os.Create(IPsFilePath)
-	}
+		case 3:
+			fmt.Printf("确定终止实例池及其中的所有实例？(输入 y 并回车): ")
+			var input string
+			fmt.Scanln(&input)
+			if strings.EqualFold(input, "y") {
+				err := terminateInstancePool(pool.Id)
+				if err != nil {
+					fmt.Printf("\033[1;31m终止实例池失败.\033[0m %s\n", err.Error())
+				} else {
+					fmt.Printf("\033[1;32m正在终止实例池, 终止完成后可删除实例配置: %s\033[0m\n", *pool.InstanceConfigurationId)
+					time.Sleep(1 * time.Second)
+					listInstancePools()
+					return
+				}
+				time.Sleep(1 * time.Second)
+			}
 
-	fmt.Printf("正在导出实例公共IP地址...\n")
-	for _, sec := range oracleSections {
-		err := initVar(sec)
-		if err != nil {
-			continue
+		default:
+			listInstancePools()
+			return
 		}
-		ListInstancesIPs(IPsFilePath, sec.Name())
 	}
-	fmt.Printf("导出实例公共IP地址完成，请查看文件 %s\n", IPsFilePath)
 }
 
-func batchListInstancesIp(filePath string, sec *ini.Section) {
-	_, err := os.Stat(filePath)
-	if err != nil && os.IsNotExist(err) {
-		os.Create(filePath)
+// 将实例模版转换为实例配置, 创建实例池, 由实例池保持实例数量
+func createInstancePoolFromTemplate() {
+	if !selectInstanceTemplate("选择实例模版创建实例池") {
+		return
 	}
-	fmt.Printf("正在导出实例公共IP地址...\n")
-	ListInstancesIPs(filePath, sec.Name())
-	fmt.Printf("导出实例IP地址完成，请查看文件 %s\n", filePath)
-}
-
-func ListInstancesIPs(filePath string, sectionName string) {
-	var vnicAttachments []core.VnicAttachment
-	var vas []core.VnicAttachment
-	var nextPage *string
-	var err error
-	for {
-		vas, nextPage, err = ListVnicAttachments(ctx, computeClient, nil, nextPage)
-		if err == nil {
-			vnicAttachments = append(vnicAttachments, vas...)
-		}
-		if nextPage == nil || len(vas) == 0 {
-			break
+	size := instance.PoolSize
+	if size <= 0 {
+		size = 1
+	}
+	fmt.Printf("请输入实例数量 (默认 %d): ", size)
+	var input string
+	fmt.Scanln(&input)
+	if input != "" {
+		n, err := strconv.Atoi(input)
+		if err != nil || n <= 0 {
+			fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+			return
 		}
+		size = int32(n)
 	}
 
+	details, subnet, shape, storageInGBs, err := buildInstanceConfigurationLaunchDetails()
 	if err != nil {
-		fmt.Printf("ListVnicAttachments Error: %s\n", err.Error())
-		return
-	}
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-	if err != nil {
-		fmt.Printf("打开文件失败, Error: %s\n", err.Error())
+		printlnErr("转换实例配置失败", err.Error())
 		return
 	}
-	_, err = io.WriteString(file, "["+sectionName+"]\n")
-	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-	}
-	for _, vnicAttachment := range vnicAttachments {
-		vnic, err := GetVnic(ctx, networkClient, vnicAttachment.VnicId)
+
+	if !instance.AllowPaid {
+		fmt.Println("正在统计 Always Free 资源...")
+		usage, err := getFreeTierUsage(availabilityDomains)
 		if err != nil {
-			fmt.Printf("IP地址获取失败, %s\n", err.Error())
-			continue
+			printlnErr("统计 Always Free 资源失败", err.Error())
+			return
 		}
-		fmt.Printf("[%s] 实例: %s, IP: %s\n", sectionName, *vnic.DisplayName, *vnic.PublicIp)
-		_, err = io.WriteString(file, "实例: "+*vnic.DisplayName+", IP: "+*vnic.PublicIp+"\n")
-		if err != nil {
-			fmt.Printf("写入文件失败, Error: %s\n", err.Error())
+		if items := usage.exceeded(*shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, storageInGBs, size); len(items) > 0 {
+			if strings.EqualFold(instance.FreeTierGuard, "warn") {
+				printf("\033[1;33m[%s] 警告: 创建后将超出 Always Free 限额, 可能产生费用. %s\033[0m\n", oracleSectionName, strings.Join(items, ", "))
+			} else {
+				printlnErr("创建后将超出 Always Free 限额, 已取消创建 (设置 allowPaid=true 跳过检查)", strings.Join(items, ", "))
+				return
+			}
 		}
 	}
-	_, err = io.WriteString(file, "\n")
-	if err != nil {
-		fmt.Printf("%s\n", err.Error())
-	}
-}
-
-// 返回值 sum: 创建实例总数; num: 创建成功的个数
-func LaunchInstances(ads []identity.AvailabilityDomain) (sum, num int32) {
-	/* 创建实例的几种情况
-	 * 1. 设置了 availabilityDomain 参数，即在设置的可用性域中创建 sum 个实例。
-	 * 2. 没有设置 availabilityDomain 但是设置了 each 参数。即在获取的每个可用性域中创建 each 个实例，创建的实例总数 sum =  each * adCount。
-	 * 3. 没有设置 availabilityDomain 且没有设置 each 参数，即在获取到的可用性域中创建的实例总数为 sum。
-	 */
-
-	//可用性域数量
-	var adCount int32 = int32(len(ads))
-	adName := common.String(instance.AvailabilityDomain)
-	each := instance.Each
-	sum = instance.Sum
-
-	// 没有设置可用性域并且没有设置each时，才有用。
-	var usableAds = make([]identity.AvailabilityDomain, 0)
 
-	//可用性域不固定，即没有提供 availabilityDomain 参数
-	var AD_NOT_FIXED bool = false
-	var EACH_AD = false
-	if adName == nil || *adName == "" {
-		AD_NOT_FIXED = true
-		if each > 0 {
-			EACH_AD = true
-			sum = each * adCount
-		} else {
-			EACH_AD = false
-			usableAds = ads
+	// 实例池中实例的可用性域和容错域由放置配置决定
+	var faultDomains []string
+	if details.FaultDomain != nil {
+		faultDomains = append(faultDomains, *details.FaultDomain)
+		details.FaultDomain = nil
+	}
+	var placements []core.CreateInstancePoolPlacementConfigurationDetails
+	for _, ad := range availabilityDomains {
+		if instance.AvailabilityDomain != "" && *ad.Name != instance.AvailabilityDomain {
+			continue
 		}
+		placements = append(placements, core.CreateInstancePoolPlacementConfigurationDetails{
+			AvailabilityDomain: ad.Name,
+			PrimarySubnetId:    subnet.Id,
+			FaultDomains:       faultDomains,
+		})
+	}
+	if len(placements) == 0 {
+		printlnErr("实例模版参数错误", "未找到可用性域 "+instance.AvailabilityDomain)
+		return
 	}
 
 	name := instance.InstanceDisplayName
 	if name == "" {
-		name = time.Now().Format("instance-20060102-1504")
-	}
-	displayName := common.String(name)
-	if sum > 1 {
-		displayName = common.String(name + "-1")
+		name = time.Now().Format("pool-20060102-1504")
 	}
-	// create the launch instance request
-	request := core.LaunchInstanceRequest{}
-	request.CompartmentId = common.String(oracle.Tenancy)
-	request.DisplayName = displayName
-
-	// Get a image.
-	fmt.Println("正在获取系统镜像...")
-	image, err := GetImage(ctx, computeClient)
-	if err != nil {
-		printlnErr("获取系统镜像失败", err.Error())
-		return
+	configDetails := core.CreateInstanceConfigurationDetails{
+		CompartmentId:   common.String(oracle.Tenancy),
+		DisplayName:     common.String(name),
+		InstanceDetails: core.ComputeInstanceDetails{LaunchDetails: &details},
 	}
-	fmt.Println("系统镜像:", *image.DisplayName)
-
-	var shape core.Shape
-	if strings.Contains(strings.ToLower(instance.Shape), "flex") && instance.Ocpus > 0 && instance.MemoryInGBs > 0 {
-		shape.Shape = &instance.Shape
-		shape.Ocpus = &instance.Ocpus
-		shape.MemoryInGBs = &instance.MemoryInGBs
-	} else {
-		fmt.Println("正在获取Shape信息...")
-		shape, err = getShape(image.Id, instance.Shape)
-		if err != nil {
-			printlnErr("获取Shape信息失败", err.Error())
-			return
-		}
+	poolDetails := core.CreateInstancePoolDetails{
+		CompartmentId:           common.String(oracle.Tenancy),
+		DisplayName:             common.String(name),
+		PlacementConfigurations: placements,
+		Size:                    common.Int(int(size)),
 	}
 
-	request.Shape = shape.Shape
-	if strings.Contains(strings.ToLower(*shape.Shape), "flex") {
-		request.ShapeConfig = &core.LaunchInstanceShapeConfigDetails{
-			Ocpus:       shape.Ocpus,
-			MemoryInGBs: shape.MemoryInGBs,
+	if isDryRun() {
+		fmt.Printf("\n\033[1;32m[%s] Dry Run, 不会创建任何资源\033[0m\n", oracleSectionName)
+		if len(networkPlan) == 0 {
+			fmt.Println("网络资源: 使用已有的网络资源")
+		} else {
+			fmt.Println("网络资源:")
+			for _, item := range networkPlan {
+				fmt.Printf("  - %s\n", item)
+			}
 		}
-		if instance.Burstable == "1/8" {
-			request.ShapeConfig.BaselineOcpuUtilization = core.LaunchInstanceShapeConfigDetailsBaselineOcpuUtilization8
-		} else if instance.Burstable == "1/2" {
-			request.ShapeConfig.BaselineOcpuUtilization = core.LaunchInstanceShapeConfigDetailsBaselineOcpuUtilization2
+		for _, v := range []interface{}{configDetails, poolDetails} {
+			data, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				printlnErr("序列化请求失败", err.Error())
+				return
+			}
+			fmt.Printf("%s\n", string(data))
 		}
+		return
 	}
 
-	// create a subnet or get the one already created
-	fmt.Println("正在获取子网...")
-	subnet, err := CreateOrGetNetworkInfrastructure(ctx, networkClient)
+	fmt.Println("正在创建实例配置...")
+	config, err := createInstanceConfiguration(configDetails)
 	if err != nil {
-		printlnErr("获取子网失败", err.Error())
+		printlnErr("创建实例配置失败", err.Error())
 		return
 	}
-	fmt.Println("子网:", *subnet.DisplayName)
-	request.CreateVnicDetails = &core.CreateVnicDetails{SubnetId: subnet.Id}
-
-	sd := core.InstanceSourceViaImageDetails{}
-	sd.ImageId = image.Id
-	if instance.BootVolumeSizeInGBs > 0 {
-		sd.BootVolumeSizeInGBs = common.Int64(instance.BootVolumeSizeInGBs)
+	fmt.Println("正在创建实例池...")
+	poolDetails.InstanceConfigurationId = config.Id
+	pool, err := createInstancePool(poolDetails)
+	if err != nil {
+		printlnErr("创建实例池失败", err.Error())
+		if err := deleteInstanceConfiguration(config.Id); err != nil {
+			printlnErr("删除实例配置失败", err.Error())
+		}
+		return
 	}
-	request.SourceDetails = sd
-	request.IsPvEncryptionInTransitEnabled = common.Bool(true)
+	printf("\033[1;32m[%s] 实例池创建成功, 实例池将保持 %d 个实例\033[0m\n", oracleSectionName, size)
+	sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("实例池创建成功\n名称: %s\n实例数量: %d", *pool.DisplayName, size))
+}
 
-	metaData := map[string]string{}
-	metaData["ssh_authorized_keys"] = instance.SSH_Public_Key
-	if instance.CloudInit != "" {
-		metaData["user_data"] = instance.CloudInit
+func listReservedIps() {
+	fmt.Println("正在获取预留公共IP...")
+	ips, err := listPublicIps(core.ListPublicIpsScopeRegion, nil, core.ListPublicIpsLifetimeReserved)
+	if err != nil {
+		printlnErr("获取失败, 回车返回上一级菜单.", err.Error())
+		fmt.Scanln()
+		showMainMenu()
+		return
 	}
-	request.Metadata = metaData
-
-	minTime := instance.MinTime
-	maxTime := instance.MaxTime
-
-	SKIP_RETRY_MAP := make(map[int32]bool)
-	var usableAdsTemp = make([]identity.AvailabilityDomain, 0)
-
-	retry := instance.Retry // 重试次数
-	var failTimes int32 = 0 // 失败次数
-
-	// 记录尝试创建实例的次数
-	var runTimes int32 = 0
-
-	var adIndex int32 = 0 // 当前可用性域下标
-	var pos int32 = 0     // for 循环次数
-	var SUCCESS = false   // 创建是否成功
 
-	var startTime = time.Now()
-
-	var bootVolumeSize float64
-	if instance.BootVolumeSizeInGBs > 0 {
-		bootVolumeSize = float64(instance.BootVolumeSizeInGBs)
-	} else {
-		bootVolumeSize = math.Round(float64(*image.SizeInMBs) / float64(1024))
+	fmt.Printf("\n\033[1;32m预留公共IP\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "名称", "公共IP", "状态")
+	for i, ip := range ips {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, *ip.DisplayName, *ip.IpAddress, getPublicIpState(ip.LifecycleState))
 	}
-	printf("\033[1;36m[%s] 开始创建 %s 实例, OCPU: %g 内存: %g 引导卷: %g \033[0m\n", oracleSectionName, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize)
-	if EACH {
-		text := fmt.Sprintf("正在尝试创建第 %d 个实例...⏳\n区域: %s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d", pos+1, oracle.Region, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum)
-		_, err := sendMessage("", text)
+	w.Flush()
+	fmt.Println("--------------------")
+	fmt.Printf("\n\033[1;32ma: %s\033[0m\n", "预留新的公共IP")
+	var input string
+	var index int
+	for {
+		fmt.Print("请输入序号查看预留公共IP详细信息: ")
+		_, err := fmt.Scanln(&input)
 		if err != nil {
-			printlnErr("Telegram 消息提醒发送失败", err.Error())
+			showMainMenu()
+			return
 		}
-	}
-
-	for pos < sum {
-
-		if AD_NOT_FIXED {
-			if EACH_AD {
-				if pos%each == 0 && failTimes == 0 {
-					adName = ads[adIndex].Name
-					adIndex++
-				}
+		if input == "a" {
+			var name string
+			fmt.Printf("请输入名称 (回车使用默认名称): ")
+			fmt.Scanln(&name)
+			if name == "" {
+				name = time.Now().Format("reserved-ip-20060102-150405")
+			}
+			ip, err := createReservedPublicIp(name)
+			if err != nil {
+				fmt.Printf("\033[1;31m预留公共IP失败.\033[0m %s\n", err.Error())
 			} else {
-				if SUCCESS {
-					adIndex = 0
-				}
-				if adIndex >= adCount {
-					adIndex = 0
-				}
-				//adName = ads[adIndex].Name
-				adName = usableAds[adIndex].Name
-				adIndex++
+				fmt.Printf("\033[1;32m预留公共IP成功: \033[0m%s\n", *ip.IpAddress)
 			}
+			time.Sleep(1 * time.Second)
+			listReservedIps()
+			return
 		}
+		index, _ = strconv.Atoi(input)
+		if 0 < index && index <= len(ips) {
+			break
+		} else {
+			input = ""
+			index = 0
+			fmt.Printf("\033[1;31m错误! 请输入正确的序号\033[0m\n")
+		}
+	}
+	reservedIpDetails(ips[index-1].Id)
+}
 
-		runTimes++
-		printf("\033[1;36m[%s] 正在尝试创建第 %d 个实例, AD: %s\033[0m\n", oracleSectionName, pos+1, *adName)
-		printf("\033[1;36m[%s] 当前尝试次数: %d \033[0m\n", oracleSectionName, runTimes)
-		request.AvailabilityDomain = adName
-		createResp, err := computeClient.LaunchInstance(ctx, request)
-
-		if err == nil {
-			// 创建实例成功
-			SUCCESS = true
-			num++ //成功个数+1
-
-			duration := fmtDuration(time.Since(startTime))
-
-			printf("\033[1;32m[%s] 第 %d 个实例抢到了🎉, 正在启动中请稍等...⌛️ \033[0m\n", oracleSectionName, pos+1)
-			var msg Message
-			var msgErr error
-			var text string
-			if EACH {
-				text = fmt.Sprintf("第 %d 个实例抢到了🎉, 正在启动中请稍等...⌛️\n区域: %s\n实例名称: %s\n公共IP: 获取中...⏳\n可用性域:%s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时: %s", pos+1, oracle.Region, *createResp.Instance.DisplayName, *createResp.Instance.AvailabilityDomain, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
-				msg, msgErr = sendMessage("", text)
-			}
-			// 获取实例公共IP
-			var strIps string
-			ips, err := getInstancePublicIps(createResp.Instance.Id)
+func reservedIpDetails(publicIpId *string) {
+	for {
+		fmt.Println("正在获取预留公共IP详细信息...")
+		resp, err := networkClient.GetPublicIp(ctx, core.GetPublicIpRequest{
+			PublicIpId:      publicIpId,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			fmt.Printf("\033[1;31m获取预留公共IP详细信息失败, 回车返回上一级菜单.\033[0m")
+			fmt.Scanln()
+			listReservedIps()
+			return
+		}
+		publicIp := resp.PublicIp
+		var assigned string
+		if publicIp.AssignedEntityId != nil {
+			ins, err := getPublicIpInstance(publicIp)
 			if err != nil {
-				printf("\033[1;32m[%s] 第 %d 个实例抢到了🎉, 但是启动失败❌ 错误信息: \033[0m%s\n", oracleSectionName, pos+1, err.Error())
-				text = fmt.Sprintf("第 %d 个实例抢到了🎉, 但是启动失败❌实例已被终止😔\n区域: %s\n实例名称: %s\n可用性域:%s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时: %s", pos+1, oracle.Region, *createResp.Instance.DisplayName, *createResp.Instance.AvailabilityDomain, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
+				assigned = err.Error()
 			} else {
-				strIps = strings.Join(ips, ",")
-				printf("\033[1;32m[%s] 第 %d 个实例抢到了🎉, 启动成功✅. 实例名称: %s, 公共IP: %s\033[0m\n", oracleSectionName, pos+1, *createResp.Instance.DisplayName, strIps)
-				text = fmt.Sprintf("第 %d 个实例抢到了🎉, 启动成功✅\n区域: %s\n实例名称: %s\n公共IP: %s\n可用性域:%s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时: %s", pos+1, oracle.Region, *createResp.Instance.DisplayName, strIps, *createResp.Instance.AvailabilityDomain, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
-			}
-			if EACH {
-				if msgErr != nil {
-					sendMessage("", text)
-				} else {
-					editMessage(msg.MessageId, "", text)
-				}
+				assigned = *ins.DisplayName
 			}
+		}
 
-			sleepRandomSecond(minTime, maxTime)
-
-			displayName = common.String(fmt.Sprintf("%s-%d", name, pos+1))
-			request.DisplayName = displayName
-
-		} else {
-			// 创建实例失败
-			SUCCESS = false
-			// 错误信息
-			errInfo := err.Error()
-			// 是否跳过重试
-			SKIP_RETRY := false
-
-			//isRetryable := common.IsErrorRetryableByDefault(err)
-			//isNetErr := common.IsNetworkError(err)
-			servErr, isServErr := common.IsServiceError(err)
-
-			// API Errors: https://docs.cloud.oracle.com/Content/API/References/apierrors.htm
-
-			if isServErr && (400 <= servErr.GetHTTPStatusCode() && servErr.GetHTTPStatusCode() <= 405) ||
-				(servErr.GetHTTPStatusCode() == 409 && !strings.EqualFold(servErr.GetCode(), "IncorrectState")) ||
-				servErr.GetHTTPStatusCode() == 412 || servErr.GetHTTPStatusCode() == 413 || servErr.GetHTTPStatusCode() == 422 ||
-				servErr.GetHTTPStatusCode() == 431 || servErr.GetHTTPStatusCode() == 501 {
-				// 不可重试
-				if isServErr {
-					errInfo = servErr.GetMessage()
-				}
-				duration := fmtDuration(time.Since(startTime))
-				printf("\033[1;31m[%s] 第 %d 个实例创建失败了❌, 错误信息: \033[0m%s\n", oracleSectionName, pos+1, errInfo)
-				if EACH {
-					text := fmt.Sprintf("第 %d 个实例创建失败了❌\n错误信息: %s\n区域: %s\n可用性域: %s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时:%s", pos+1, errInfo, oracle.Region, *adName, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
-					sendMessage("", text)
-				}
-
-				SKIP_RETRY = true
-				if AD_NOT_FIXED && !EACH_AD {
-					SKIP_RETRY_MAP[adIndex-1] = true
-				}
+		fmt.Printf("\n\033[1;32m预留公共IP详细信息\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+		fmt.Println("--------------------")
+		fmt.Printf("名称: %s\n", *publicIp.DisplayName)
+		fmt.Printf("公共IP: %s\n", *publicIp.IpAddress)
+		fmt.Printf("OCID: %s\n", *publicIp.Id)
+		fmt.Printf("状态: %s\n", getPublicIpState(publicIp.LifecycleState))
+		if assigned != "" {
+			fmt.Printf("已分配给实例: %s\n", assigned)
+		}
+		fmt.Println("--------------------")
+		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s\033[0m\n", "分配到实例", "取消分配", "释放")
+		var input string
+		var num int
+		fmt.Print("\n请输入需要执行操作的序号: ")
+		fmt.Scanln(&input)
+		num, _ = strconv.Atoi(input)
+		switch num {
+		case 1:
+			assignReservedIp(publicIp)
 
+		case 2:
+			_, err := updatePublicIp(publicIp.Id, common.String(""))
+			if err != nil {
+				fmt.Printf("\033[1;31m取消分配失败.\033[0m %s\n", err.Error())
 			} else {
-				// 可重试
-				if isServErr {
-					errInfo = servErr.GetMessage()
-				}
-				printf("\033[1;31m[%s] 创建失败, Error: \033[0m%s\n", oracleSectionName, errInfo)
-
-				SKIP_RETRY = false
-				if AD_NOT_FIXED && !EACH_AD {
-					SKIP_RETRY_MAP[adIndex-1] = false
-				}
+				fmt.Printf("\033[1;32m正在取消分配, 请稍后查看预留公共IP状态\033[0m\n")
 			}
+			time.Sleep(1 * time.Second)
 
-			sleepRandomSecond(minTime, maxTime)
-
-			if AD_NOT_FIXED {
-				if !EACH_AD {
-					if adIndex < adCount {
-						// 没有设置可用性域，且没有设置each。即在获取到的每个可用性域里尝试创建。当前使用的可用性域不是最后一个，继续尝试。
-						continue
-					} else {
-						// 当前使用的可用性域是最后一个，判断失败次数是否达到重试次数，未达到重试次数继续尝试。
-						failTimes++
-
-						for index, skip := range SKIP_RETRY_MAP {
-							if !skip {
-								usableAdsTemp = append(usableAdsTemp, usableAds[index])
-							}
-						}
-
-						// 重新设置 usableAds
-						usableAds = usableAdsTemp
-						adCount = int32(len(usableAds))
-
-						// 重置变量
-						usableAdsTemp = nil
-						for k := range SKIP_RETRY_MAP {
-							delete(SKIP_RETRY_MAP, k)
-						}
-
-						// 判断是否需要重试
-						if (retry < 0 || failTimes <= retry) && adCount > 0 {
-							continue
-						}
-					}
-
-					adIndex = 0
-
+		case 3:
+			fmt.Printf("释放后无法找回该公共IP。确定释放预留公共IP？(输入 y 并回车): ")
+			var input string
+			fmt.Scanln(&input)
+			if strings.EqualFold(input, "y") {
+				_, err := deletePublicIp(publicIp.Id)
+				if err != nil {
+					fmt.Printf("\033[1;31m释放预留公共IP失败.\033[0m %s\n", err.Error())
 				} else {
-					// 没有设置可用性域，且设置了each，即在每个域创建each个实例。判断失败次数继续尝试。
-					failTimes++
-					if (retry < 0 || failTimes <= retry) && !SKIP_RETRY {
-						continue
-					}
-				}
-
-			} else {
-				//设置了可用性域，判断是否需要重试
-				failTimes++
-				if (retry < 0 || failTimes <= retry) && !SKIP_RETRY {
-					continue
+					fmt.Printf("\033[1;32m释放预留公共IP成功.\033[0m\n")
+					time.Sleep(1 * time.Second)
+					listReservedIps()
+					return
 				}
+				time.Sleep(1 * time.Second)
 			}
 
+		default:
+			listReservedIps()
+			return
 		}
+	}
+}
 
-		// 重置变量
-		usableAds = ads
-		adCount = int32(len(usableAds))
-		usableAdsTemp = nil
-		for k := range SKIP_RETRY_MAP {
-			delete(SKIP_RETRY_MAP, k)
+// 选择实例, 将预留公共IP分配到实例主网卡的主私有IP。已分配给其他实例时会移动到所选实例
+func assignReservedIp(publicIp core.PublicIp) {
+	fmt.Println("正在获取实例数据...")
+	var instances []core.Instance
+	var nextPage *string
+	for {
+		ins, page, err := ListInstances(ctx, computeClient, nextPage)
+		if err != nil {
+			printlnErr("获取实例失败", err.Error())
+			return
 		}
-
-		// 成功或者失败次数达到重试次数，重置失败次数为0
-		failTimes = 0
-
-		// 重置尝试创建实例次数
-		runTimes = 0
-		startTime = time.Now()
-
-		// for 循环次数+1
-		pos++
-
-		if pos < sum && EACH {
-			text := fmt.Sprintf("正在尝试创建第 %d 个实例...⏳\n区域: %s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d", pos+1, oracle.Region, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum)
-			sendMessage("", text)
+		for _, i := range ins {
+			if i.LifecycleState != core.InstanceLifecycleStateTerminating && i.LifecycleState != core.InstanceLifecycleStateTerminated {
+				instances = append(instances, i)
+			}
+		}
+		nextPage = page
+		if nextPage == nil || len(ins) == 0 {
+			break
 		}
 	}
-	return
-}
-
-func sleepRandomSecond(min, max int32) {
-	var second int32
-	if min <= 0 || max <= 0 {
-		second = 1
-	} else if min >= max {
-		second = max
+	if len(instances) == 0 {
+		fmt.Printf("\033[1;31m没有可用的实例.\033[0m\n")
+		return
+	}
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t\n", "序号", "名称", "状态　　")
+	for i, ins := range instances {
+		fmt.Fprintf(w, "%d\t%s\t%s\t\n", i+1, *ins.DisplayName, getInstanceState(ins.LifecycleState))
+	}
+	w.Flush()
+	var input string
+	fmt.Print("\n请输入实例的序号: ")
+	fmt.Scanln(&input)
+	index, _ := strconv.Atoi(input)
+	if index <= 0 || index > len(instances) {
+		fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+		return
+	}
+	ins := instances[index-1]
+	vnics, err := getInstanceVnics(ins.Id)
+	if err != nil || len(vnics) == 0 {
+		fmt.Printf("\033[1;31m获取实例VNIC失败.\033[0m\n")
+		return
+	}
+	fmt.Printf("实例 %s 当前的公共IP将被删除 (预留公共IP将取消分配)。确定分配？(输入 y 并回车): ", *ins.DisplayName)
+	input = ""
+	fmt.Scanln(&input)
+	if !strings.EqualFold(input, "y") {
+		return
+	}
+	_, err = changePublicIp(vnics, publicIp.Id)
+	if err != nil {
+		fmt.Printf("\033[1;31m分配预留公共IP失败.\033[0m %s\n", err.Error())
 	} else {
-		second = rand.Int31n(max-min) + min
+		fmt.Printf("\033[1;32m分配预留公共IP成功, 实例 %s 公共IP: \033[0m%s\n", *ins.DisplayName, *publicIp.IpAddress)
+		runInstanceHook(ins, hookOnIpChange, []string{*publicIp.IpAddress})
 	}
-	printf("Sleep %d Second...\n", second)
-	time.Sleep(time.Duration(second) * time.Second)
+	time.Sleep(1 * time.Second)
 }
 
-// ExampleLaunchInstance does create an instance
-// NOTE: launch instance will create a new instance and VCN. please make sure delete the instance
-// after execute this sample code, otherwise, you will be charged for the running instance
-func ExampleLaunchInstance() {
-	c, err := core.NewComputeClientWithConfigurationProvider(provider)
-	helpers.FatalIfError(err)
-	networkClient, err := core.NewVirtualNetworkClientWithConfigurationProvider(provider)
-	helpers.FatalIfError(err)
-	ctx := context.Background()
+// 选择未分配的预留公共IP, 返回 nil 表示创建新的临时公共IP, ok 为 false 表示输入错误
+func selectReservedIp() (reservedIp *core.PublicIp, ok bool) {
+	ips, err := listPublicIps(core.ListPublicIpsScopeRegion, nil, core.ListPublicIpsLifetimeReserved)
+	if err != nil {
+		printlnErr("获取预留公共IP失败", err.Error())
+		return nil, true
+	}
+	var available []core.PublicIp
+	for _, ip := range ips {
+		if ip.LifecycleState == core.PublicIpLifecycleStateAvailable || ip.LifecycleState == core.PublicIpLifecycleStateUnassigned {
+			available = append(available, ip)
+		}
+	}
+	if len(available) == 0 {
+		return nil, true
+	}
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t\n", "序号", "名称", "预留公共IP")
+	for i, ip := range available {
+		fmt.Fprintf(w, "%d\t%s\t%s\t\n", i+1, *ip.DisplayName, *ip.IpAddress)
+	}
+	w.Flush()
+	var input string
+	fmt.Print("\n请输入需要使用的预留公共IP的序号 (直接回车创建新的临时公共IP): ")
+	fmt.Scanln(&input)
+	if input == "" {
+		return nil, true
+	}
+	index, _ := strconv.Atoi(input)
+	if index <= 0 || index > len(available) {
+		fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+		return nil, false
+	}
+	return &available[index-1], true
+}
 
-	// create the launch instance request
-	request := core.LaunchInstanceRequest{}
-	request.CompartmentId = common.String(oracle.Tenancy)
-	request.DisplayName = common.String(instance.InstanceDisplayName)
-	request.AvailabilityDomain = common.String(instance.AvailabilityDomain)
+// 安全列表或网络安全组
+type firewallTarget struct {
+	Nsg     bool
+	Id      *string
+	Name    string
+	VcnName string
+	Subnets []string // 使用该安全列表的子网
+}
 
-	// create a subnet or get the one already created
-	subnet, err := CreateOrGetNetworkInfrastructure(ctx, networkClient)
-	helpers.FatalIfError(err)
+func listFirewalls() {
+	fmt.Println("正在获取安全列表和网络安全组...")
+	var targets []firewallTarget
+	vcns, err := listVcns(ctx, networkClient)
+	for _, vcn := range vcns {
+		if err != nil {
+			break
+		}
+		var subnets []core.Subnet
+		subnets, err = listSubnets(ctx, networkClient, vcn.Id)
+		if err != nil {
+			break
+		}
+		var resp core.ListSecurityListsResponse
+		resp, err = networkClient.ListSecurityLists(ctx, core.ListSecurityListsRequest{
+			CompartmentId:   &oracle.Tenancy,
+			VcnId:           vcn.Id,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			break
+		}
+		for _, sl := range resp.Items {
+			target := firewallTarget{Id: sl.Id, Name: *sl.DisplayName, VcnName: *vcn.DisplayName}
+			for _, subnet := range subnets {
+				for _, id := range subnet.SecurityListIds {
+					if id == *sl.Id {
+						target.Subnets = append(target.Subnets, *subnet.DisplayName)
+					}
+				}
+			}
+			targets = append(targets, target)
+		}
+		var nsgs []core.NetworkSecurityGroup
+		nsgs, err = listNetworkSecurityGroups(ctx, networkClient, vcn.Id)
+		for _, nsg := range nsgs {
+			targets = append(targets, firewallTarget{Nsg: true, Id: nsg.Id, Name: *nsg.DisplayName, VcnName: *vcn.DisplayName})
+		}
+	}
+	if err != nil {
+		printlnErr("获取失败, 回车返回上一级菜单.", err.Error())
+		fmt.Scanln()
+		showMainMenu()
+		return
+	}
+	if len(targets) == 0 {
+		fmt.Printf("\033[1;32m没有安全列表和网络安全组, 回车返回上一级菜单.\033[0m")
+		fmt.Scanln()
+		showMainMenu()
+		return
+	}
+
+	fmt.Printf("\n\033[1;32m防火墙规则\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", "序号", "类型", "名称", "VCN", "子网")
+	for i, target := range targets {
+		kind := "安全列表"
+		if target.Nsg {
+			kind = "网络安全组"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t\n", i+1, kind, target.Name, target.VcnName, strings.Join(target.Subnets, ","))
+	}
+	w.Flush()
+	fmt.Println("--------------------")
+	var input string
+	var index int
+	for {
+		fmt.Print("请输入序号查看入站规则: ")
+		_, err := fmt.Scanln(&input)
+		if err != nil {
+			showMainMenu()
+			return
+		}
+		index, _ = strconv.Atoi(input)
+		if 0 < index && index <= len(targets) {
+			break
+		} else {
+			input = ""
+			index = 0
+			fmt.Printf("\033[1;31m错误! 请输入正确的序号\033[0m\n")
+		}
+	}
+	firewallDetails(targets[index-1])
+}
+
+// 查看和修改安全列表或网络安全组的入站规则
+func firewallDetails(target firewallTarget) {
+	for {
+		fmt.Println("正在获取入站规则...")
+		var rules []firewallRule
+		var ingress []core.IngressSecurityRule // 安全列表的入站规则
+		var nsgRules []core.SecurityRule       // 网络安全组的入站规则
+		var err error
+		if target.Nsg {
+			nsgRules, err = listNsgIngressRules(ctx, networkClient, target.Id)
+			for _, r := range nsgRules {
+				rules = append(rules, firewallRuleFromSecurityRule(r))
+			}
+		} else {
+			var resp core.GetSecurityListResponse
+			resp, err = networkClient.GetSecurityList(ctx, core.GetSecurityListRequest{
+				SecurityListId:  target.Id,
+				RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+			})
+			ingress = resp.IngressSecurityRules
+			for _, r := range ingress {
+				rules = append(rules, firewallRuleFromIngress(r))
+			}
+		}
+		if err != nil {
+			fmt.Printf("\033[1;31m获取入站规则失败, 回车返回上一级菜单.\033[0m %s", err.Error())
+			fmt.Scanln()
+			listFirewalls()
+			return
+		}
+
+		fmt.Printf("\n\033[1;32m入站规则\033[0m \n(当前账号: %s)\n\n", oracleSection.Name())
+		fmt.Printf("名称: %s\n", target.Name)
+		fmt.Printf("VCN: %s\n", target.VcnName)
+		if len(target.Subnets) > 0 {
+			fmt.Printf("子网: %s\n", strings.Join(target.Subnets, ","))
+		}
+		fmt.Println("--------------------")
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "协议/端口", "来源", "说明")
+		for i, r := range rules {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, r.String(), r.Source, r.Description)
+		}
+		w.Flush()
+		fmt.Println("--------------------")
+		fmt.Printf("\n\033[1;32m1: %s   2: %s   3: %s\033[0m\n", "添加规则", "删除规则", "应用配置文件中的规则")
+		var input string
+		var num int
+		fmt.Print("\n请输入需要执行操作的序号: ")
+		fmt.Scanln(&input)
+		num, _ = strconv.Atoi(input)
+		switch num {
+		case 1:
+			fmt.Print("请输入规则 (格式: 协议/端口|来源|说明, 例如 tcp/443|0.0.0.0/0|HTTPS): ")
+			reader := bufio.NewReader(os.Stdin)
+			line, _ := reader.ReadString('\n')
+			rule, err := parseFirewallRule("input", strings.TrimSpace(line))
+			if err == nil {
+				if target.Nsg {
+					err = addNsgRules(ctx, networkClient, target.Id, []firewallRule{rule})
+				} else {
+					err = updateSecurityListIngress(ctx, networkClient, target.Id, append(ingress, rule.ingressSecurityRule()))
+				}
+			}
+			if err != nil {
+				fmt.Printf("\033[1;31m添加规则失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m添加规则成功.\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
+
+		case 2:
+			fmt.Print("请输入需要删除的规则序号: ")
+			var input string
+			fmt.Scanln(&input)
+			index, _ := strconv.Atoi(input)
+			if index <= 0 || index > len(rules) {
+				fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+				continue
+			}
+			if target.Nsg {
+				err = removeNsgRules(ctx, networkClient, target.Id, []string{*nsgRules[index-1].Id})
+			} else {
+				remain := append(append([]core.IngressSecurityRule{}, ingress[:index-1]...), ingress[index:]...)
+				err = updateSecurityListIngress(ctx, networkClient, target.Id, remain)
+			}
+			if err != nil {
+				fmt.Printf("\033[1;31m删除规则失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m删除规则成功.\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
+
+		case 3:
+			fmt.Print("请输入防火墙规则组名称 (回车使用 FIREWALL): ")
+			var name string
+			fmt.Scanln(&name)
+			configRules, err := loadFirewallRules(name)
+			if err != nil {
+				fmt.Printf("\033[1;31m读取防火墙规则失败.\033[0m %s\n", err.Error())
+				time.Sleep(1 * time.Second)
+				continue
+			}
+			if len(configRules) == 0 {
+				fmt.Printf("\033[1;31m配置文件中没有防火墙规则.\033[0m\n")
+				time.Sleep(1 * time.Second)
+				continue
+			}
+			fmt.Printf("现有入站规则将被替换为: %s\n确定应用？(输入 y 并回车): ", firewallRulesDesc(configRules))
+			var input string
+			fmt.Scanln(&input)
+			if !strings.EqualFold(input, "y") {
+				continue
+			}
+			if target.Nsg {
+				err = syncNsgRules(ctx, networkClient, target.Id, configRules)
+			} else {
+				var newIngress []core.IngressSecurityRule
+				for _, r := range configRules {
+					newIngress = append(newIngress, r.ingressSecurityRule())
+				}
+				err = updateSecurityListIngress(ctx, networkClient, target.Id, newIngress)
+			}
+			if err != nil {
+				fmt.Printf("\033[1;31m应用规则失败.\033[0m %s\n", err.Error())
+			} else {
+				fmt.Printf("\033[1;32m应用规则成功.\033[0m\n")
+			}
+			time.Sleep(1 * time.Second)
+
+		default:
+			listFirewalls()
+			return
+		}
+	}
+}
+
+// 选择实例模版 (使用模版中的实例配置、网络和SSH公钥), 使用指定的引导卷创建实例
+func relaunchFromBootVolume(bootVolume core.BootVolume) {
+	if !selectInstanceTemplate(fmt.Sprintf("选择实例模版, 使用引导卷 %s 创建实例", *bootVolume.DisplayName)) {
+		return
+	}
+	instance.Source = "bootVolume"
+	instance.BootVolume = *bootVolume.Id
+	LaunchInstances(availabilityDomains)
+}
+
+// 选择实例模版并解析到 instance, 选择成功返回 true
+func selectInstanceTemplate(title string) bool {
+	var instanceSections []*ini.Section
+	instanceSections = append(instanceSections, instanceBaseSection.ChildSections()...)
+	instanceSections = append(instanceSections, oracleSection.ChildSections()...)
+	if len(instanceSections) == 0 {
+		fmt.Printf("\033[1;31m未找到实例模版.\033[0m\n")
+		return false
+	}
+	fmt.Printf("\n\033[1;32m%s\033[0m\n\n", title)
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "配置", "CPU个数", "内存(GB)")
+	for i, instanceSec := range instanceSections {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, instanceSec.Key("shape").Value(), instanceSec.Key("cpus").Value(), instanceSec.Key("memoryInGBs").Value())
+	}
+	w.Flush()
+	var input string
+	fmt.Print("\n请输入实例模版的序号: ")
+	fmt.Scanln(&input)
+	index, _ := strconv.Atoi(input)
+	if index <= 0 || index > len(instanceSections) {
+		fmt.Printf("\033[1;31m输入错误.\033[0m\n")
+		return false
+	}
+	instanceSectionName = instanceSections[index-1].Name()
+	instance = Instance{}
+	err := instanceSections[index-1].MapTo(&instance)
+	if err != nil {
+		printlnErr("解析实例模版参数失败", err.Error())
+		return false
+	}
+	return true
+}
+
+func listLaunchInstanceTemplates() {
+	var instanceSections []*ini.Section
+	instanceSections = append(instanceSections, instanceBaseSection.ChildSections()...)
+	instanceSections = append(instanceSections, oracleSection.ChildSections()...)
+	if len(instanceSections) == 0 {
+		fmt.Printf("\033[1;31m未找到实例模版, 回车返回上一级菜单.\033[0m")
+		fmt.Scanln()
+		showMainMenu()
+		return
+	}
+
+	for {
+		fmt.Printf("\n\033[1;32m选择对应的实例模版开始创建实例\033[0m \n(当前账号: %s)\n\n", oracleSectionName)
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 8, 1, '\t', 0)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "序号", "配置", "CPU个数", "内存(GB)")
+		for i, instanceSec := range instanceSections {
+			cpu := instanceSec.Key("cpus").Value()
+			if cpu == "" {
+				cpu = "-"
+			}
+			memory := instanceSec.Key("memoryInGBs").Value()
+			if memory == "" {
+				memory = "-"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", i+1, instanceSec.Key("shape").Value(), cpu, memory)
+		}
+		w.Flush()
+		fmt.Printf("\n")
+		var input string
+		var index int
+		for {
+			fmt.Print("请输入需要创建的实例的序号: ")
+			_, err := fmt.Scanln(&input)
+			if err != nil {
+				showMainMenu()
+				return
+			}
+			index, _ = strconv.Atoi(input)
+			if 0 < index && index <= len(instanceSections) {
+				break
+			} else {
+				input = ""
+				index = 0
+				fmt.Printf("\033[1;31m错误! 请输入正确的序号\033[0m\n")
+			}
+		}
+
+		instanceSection := instanceSections[index-1]
+		instanceSectionName = instanceSection.Name()
+		instance = Instance{}
+		err := instanceSection.MapTo(&instance)
+		if err != nil {
+			printlnErr("解析实例模版参数失败", err.Error())
+			continue
+		}
+
+		LaunchInstances(availabilityDomains)
+	}
+
+}
+
+func multiBatchLaunchInstances() {
+	IPsFilePath := IPsFilePrefix + "-" + time.Now().Format("2006-01-02-150405.txt")
+	for _, sec := range oracleSections {
+		var err error
+		err = initVar(sec)
+		if err != nil {
+			continue
+		}
+		// 获取可用性域
+		availabilityDomains, err = ListAvailabilityDomains()
+		if err != nil {
+			printlnErr("获取可用性域失败", err.Error())
+			continue
+		}
+		batchLaunchInstances(sec)
+		batchListInstancesIp(IPsFilePath, sec)
+		command(cmd)
+		sleepRandomSecond(5, 5)
+	}
+}
+
+func batchLaunchInstances(oracleSec *ini.Section) {
+	var instanceSections []*ini.Section
+	instanceSections = append(instanceSections, instanceBaseSection.ChildSections()...)
+	instanceSections = append(instanceSections, oracleSec.ChildSections()...)
+	if len(instanceSections) == 0 {
+		return
+	}
+
+	printf("\033[1;36m[%s] 开始创建\033[0m\n", oracleSectionName)
+	var SUM, NUM int32 = 0, 0
+	sendMessage(fmt.Sprintf("[%s]", oracleSectionName), "开始创建")
+
+	for _, instanceSec := range instanceSections {
+		instanceSectionName = instanceSec.Name()
+		instance = Instance{}
+		err := instanceSec.MapTo(&instance)
+		if err != nil {
+			printlnErr("解析实例模版参数失败", err.Error())
+			continue
+		}
+
+		sum, num, abort := LaunchInstances(availabilityDomains)
+
+		SUM = SUM + sum
+		NUM = NUM + num
+
+		if abort {
+			break
+		}
+
+	}
+	printf("\033[1;36m[%s] 结束创建。创建实例总数: %d, 成功 %d , 失败 %d\033[0m\n", oracleSectionName, SUM, NUM, SUM-NUM)
+	text := fmt.Sprintf("结束创建。创建实例总数: %d, 成功 %d , 失败 %d", SUM, NUM, SUM-NUM)
+	sendMessage(fmt.Sprintf("[%s]", oracleSectionName), text)
+}
+
+func multiBatchListInstancesIp() {
+	IPsFilePath := IPsFilePrefix + "-" + time.Now().Format("2006-01-02-150405.txt")
+	_, err := os.Stat(IPsFilePath)
+	if err != nil && os.IsNotExist(err) {
+		os.Create(IPsFilePath)
+	}
+
+	fmt.Printf("正在导出实例公共IP地址...\n")
+	for _, sec := range oracleSections {
+		err := initVar(sec)
+		if err != nil {
+			continue
+		}
+		ListInstancesIPs(IPsFilePath, sec.Name())
+		exportInventory(IPsFilePath, sec.Name())
+	}
+	fmt.Printf("导出实例公共IP地址完成，请查看文件 %s\n", IPsFilePath)
+}
+
+func batchListInstancesIp(filePath string, sec *ini.Section) {
+	_, err := os.Stat(filePath)
+	if err != nil && os.IsNotExist(err) {
+		os.Create(filePath)
+	}
+	fmt.Printf("正在导出实例公共IP地址...\n")
+	ListInstancesIPs(filePath, sec.Name())
+	exportInventory(filePath, sec.Name())
+	fmt.Printf("导出实例IP地址完成，请查看文件 %s\n", filePath)
+}
+
+// 按实例导出IP地址: 实例名称、OCID、状态, 主网卡和辅助网卡的私有IP、公共IP (预留/临时) 和 IPv6 地址, 跳过已终止的实例
+func ListInstancesIPs(filePath string, sectionName string) {
+	var instances []core.Instance
+	var ins []core.Instance
+	var nextPage *string
+	var err error
+	for {
+		ins, nextPage, err = ListInstances(ctx, computeClient, nextPage)
+		if err != nil {
+			fmt.Printf("ListInstances Error: %s\n", err.Error())
+			return
+		}
+		instances = append(instances, ins...)
+		if nextPage == nil || len(ins) == 0 {
+			break
+		}
+	}
+	ads, err := ListAvailabilityDomains()
+	if err != nil {
+		fmt.Printf("ListAvailabilityDomains Error: %s\n", err.Error())
+		return
+	}
+	publicIps, err := getPublicIpsByPrivateIp(ads)
+	if err != nil {
+		fmt.Printf("ListPublicIps Error: %s\n", err.Error())
+		return
+	}
+
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
+	if err != nil {
+		fmt.Printf("打开文件失败, Error: %s\n", err.Error())
+		return
+	}
+	defer file.Close()
+	var buffer bytes.Buffer
+	buffer.WriteString("[" + sectionName + "]\n")
+	for _, ins := range instances {
+		if ins.LifecycleState == core.InstanceLifecycleStateTerminating || ins.LifecycleState == core.InstanceLifecycleStateTerminated {
+			continue
+		}
+		fmt.Fprintf(&buffer, "实例: %s, 状态: %s\n", *ins.DisplayName, strings.TrimSpace(getInstanceState(ins.LifecycleState)))
+		fmt.Fprintf(&buffer, "  OCID: %s\n", *ins.Id)
+		vnics, err := getInstanceVnics(ins.Id)
+		if err != nil {
+			fmt.Printf("IP地址获取失败, %s\n", err.Error())
+			continue
+		}
+		// 主网卡排在前面
+		sort.SliceStable(vnics, func(i, j int) bool {
+			return vnics[i].IsPrimary != nil && *vnics[i].IsPrimary && (vnics[j].IsPrimary == nil || !*vnics[j].IsPrimary)
+		})
+		var summary []string
+		for _, vnic := range vnics {
+			vnicType := "辅助网卡"
+			if vnic.IsPrimary != nil && *vnic.IsPrimary {
+				vnicType = "主网卡"
+			}
+			fmt.Fprintf(&buffer, "  VNIC: %s (%s)\n", *vnic.DisplayName, vnicType)
+			privateIps, err := getPrivateIps(vnic.Id)
+			if err != nil {
+				fmt.Printf("私有IP获取失败, %s\n", err.Error())
+			}
+			for _, privateIp := range privateIps {
+				line := "    私有IP: " + *privateIp.IpAddress
+				if publicIp, ok := publicIps[*privateIp.Id]; ok {
+					lifetime := "临时"
+					if publicIp.Lifetime == core.PublicIpLifetimeReserved {
+						lifetime = "预留"
+					}
+					line += fmt.Sprintf(", 公共IP: %s (%s)", *publicIp.IpAddress, lifetime)
+					summary = append(summary, *publicIp.IpAddress)
+				}
+				buffer.WriteString(line + "\n")
+			}
+			ipv6s, err := getIpv6s(vnic.Id)
+			if err != nil {
+				fmt.Printf("IPv6地址获取失败, %s\n", err.Error())
+			}
+			for _, ipv6 := range ipv6s {
+				fmt.Fprintf(&buffer, "    IPv6: %s\n", *ipv6.IpAddress)
+				summary = append(summary, *ipv6.IpAddress)
+			}
+		}
+		fmt.Printf("[%s] 实例: %s, IP: %s\n", sectionName, *ins.DisplayName, strings.Join(summary, ", "))
+	}
+	buffer.WriteString("\n")
+	_, err = io.WriteString(file, buffer.String())
+	if err != nil {
+		fmt.Printf("写入文件失败, Error: %s\n", err.Error())
+	}
+}
+
+// 获取账号中已分配的预留公共IP和各个可用性域中的临时公共IP, 返回 私有IP OCID -> 公共IP
+func getPublicIpsByPrivateIp(ads []identity.AvailabilityDomain) (publicIps map[string]core.PublicIp, err error) {
+	publicIps = make(map[string]core.PublicIp)
+	var ips []core.PublicIp
+	ips, err = listPublicIps(core.ListPublicIpsScopeRegion, nil, core.ListPublicIpsLifetimeReserved)
+	if err != nil {
+		return
+	}
+	for _, ad := range ads {
+		var ephemeral []core.PublicIp
+		ephemeral, err = listPublicIps(core.ListPublicIpsScopeAvailabilityDomain, ad.Name, core.ListPublicIpsLifetimeEphemeral)
+		if err != nil {
+			return
+		}
+		ips = append(ips, ephemeral...)
+	}
+	for _, ip := range ips {
+		if ip.AssignedEntityType == core.PublicIpAssignedEntityTypePrivateIp && ip.AssignedEntityId != nil {
+			publicIps[*ip.AssignedEntityId] = ip
+		}
+	}
+	return
+}
+
+// 按照 exportFormats 导出当前账号的实例, 多个账号导出到同一组文件
+func exportInventory(filePath string, sectionName string) {
+	if exportFormats == "" {
+		return
+	}
+	hosts, err := collectInventory(sectionName)
+	if err != nil {
+		printlnErr("获取实例信息失败", err.Error())
+		return
+	}
+	if filePath != inventoryPath {
+		inventoryPath = filePath
+		inventoryHosts = nil
+	}
+	inventoryHosts = append(inventoryHosts, hosts...)
+	hosts = inventoryHosts
+
+	// 主机名重复时添加 OCID 后缀
+	count := make(map[string]int)
+	for _, h := range hosts {
+		count[h.Name]++
+	}
+	for i := range hosts {
+		hosts[i].Alias = strings.ReplaceAll(hosts[i].Name, " ", "-")
+		if count[hosts[i].Name] > 1 && len(hosts[i].InstanceId) > 6 {
+			hosts[i].Alias += "-" + hosts[i].InstanceId[len(hosts[i].InstanceId)-6:]
+		}
+	}
+
+	suffix := strings.TrimSuffix(strings.TrimPrefix(filePath, IPsFilePrefix), ".txt")
+	for _, format := range strings.Split(exportFormats, ",") {
+		var path string
+		var data []byte
+		switch strings.ToLower(strings.TrimSpace(format)) {
+		case "ansible":
+			path, data = "inventory"+suffix+".ini", ansibleIniInventory(hosts)
+		case "ansible-yaml":
+			path, data = "inventory"+suffix+".yml", ansibleYamlInventory(hosts)
+		case "ssh":
+			path, data = "ssh_config"+suffix, sshConfig(hosts)
+		case "csv":
+			path = "instances" + suffix + ".csv"
+			data, err = csvInventory(hosts)
+		case "json":
+			path = "instances" + suffix + ".json"
+			data, err = json.MarshalIndent(hosts, "", "  ")
+		case "", "txt":
+			continue
+		default:
+			printlnErr("不支持的导出格式", format)
+			continue
+		}
+		if err == nil {
+			err = ioutil.WriteFile(path, data, 0644)
+		}
+		if err != nil {
+			printlnErr("导出 "+path+" 失败", err.Error())
+			continue
+		}
+		fmt.Printf("已导出 %s\n", path)
+	}
+}
+
+// 获取当前账号中未终止的实例, 主网卡的公共IP和私有IP, 以及系统镜像对应的 SSH 用户
+func collectInventory(sectionName string) (hosts []inventoryHost, err error) {
+	var instances []core.Instance
+	var nextPage *string
+	for {
+		var ins []core.Instance
+		ins, nextPage, err = ListInstances(ctx, computeClient, nextPage)
+		if err != nil {
+			return
+		}
+		instances = append(instances, ins...)
+		if nextPage == nil || len(ins) == 0 {
+			break
+		}
+	}
+	systems := make(map[string]string) // 镜像 OCID -> 系统
+	for _, ins := range instances {
+		if ins.LifecycleState == core.InstanceLifecycleStateTerminating || ins.LifecycleState == core.InstanceLifecycleStateTerminated {
+			continue
+		}
+		host := inventoryHost{
+			Account:            sectionName,
+			Region:             *ins.Region,
+			Name:               *ins.DisplayName,
+			InstanceId:         *ins.Id,
+			State:              string(ins.LifecycleState),
+			AvailabilityDomain: *ins.AvailabilityDomain,
+			Shape:              *ins.Shape,
+		}
+		if ins.ImageId != nil {
+			system, ok := systems[*ins.ImageId]
+			if !ok {
+				resp, err := computeClient.GetImage(ctx, core.GetImageRequest{
+					ImageId:         ins.ImageId,
+					RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+				})
+				if err == nil && resp.OperatingSystem != nil {
+					system = *resp.OperatingSystem
+				}
+				systems[*ins.ImageId] = system
+			}
+			host.OperatingSystem = system
+		}
+		host.User = sshUser(host.OperatingSystem)
+		vnics, err := getInstanceVnics(ins.Id)
+		if err != nil {
+			printlnErr("获取实例VNIC失败", err.Error())
+		}
+		for _, vnic := range vnics {
+			if vnic.IsPrimary == nil || !*vnic.IsPrimary {
+				continue
+			}
+			if vnic.PublicIp != nil {
+				host.PublicIp = *vnic.PublicIp
+			}
+			if vnic.PrivateIp != nil {
+				host.PrivateIp = *vnic.PrivateIp
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	return
+}
+
+// 根据系统镜像获取默认的 SSH 用户
+func sshUser(operatingSystem string) string {
+	if strings.Contains(strings.ToLower(operatingSystem), "ubuntu") {
+		return "ubuntu"
+	}
+	// Oracle Linux、CentOS 等
+	return "opc"
+}
+
+// Ansible 清单中的组名只能包含字母、数字和下划线
+var ansibleGroupInvalidRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func ansibleGroupName(prefix, name string) string {
+	return prefix + "_" + ansibleGroupInvalidRegexp.ReplaceAllString(name, "_")
+}
+
+// 按账号、区域和 Shape 分组
+func ansibleGroups(hosts []inventoryHost) (names []string, groups map[string][]inventoryHost) {
+	groups = make(map[string][]inventoryHost)
+	for _, h := range hosts {
+		if h.PublicIp == "" && h.PrivateIp == "" {
+			continue
+		}
+		for _, group := range []string{ansibleGroupName("account", h.Account), ansibleGroupName("region", h.Region), ansibleGroupName("shape", h.Shape)} {
+			if _, ok := groups[group]; !ok {
+				names = append(names, group)
+			}
+			groups[group] = append(groups[group], h)
+		}
+	}
+	return
+}
+
+func ansibleHost(h inventoryHost) string {
+	if h.PublicIp != "" {
+		return h.PublicIp
+	}
+	return h.PrivateIp
+}
+
+func ansibleIniInventory(hosts []inventoryHost) []byte {
+	var buffer bytes.Buffer
+	names, groups := ansibleGroups(hosts)
+	for _, name := range names {
+		fmt.Fprintf(&buffer, "[%s]\n", name)
+		for _, h := range groups[name] {
+			fmt.Fprintf(&buffer, "%s ansible_host=%s ansible_user=%s\n", h.Alias, ansibleHost(h), h.User)
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes()
+}
+
+func ansibleYamlInventory(hosts []inventoryHost) []byte {
+	var buffer bytes.Buffer
+	names, groups := ansibleGroups(hosts)
+	buffer.WriteString("all:\n  children:\n")
+	for _, name := range names {
+		fmt.Fprintf(&buffer, "    %s:\n      hosts:\n", name)
+		for _, h := range groups[name] {
+			fmt.Fprintf(&buffer, "        %q:\n          ansible_host: %s\n          ansible_user: %s\n", h.Alias, ansibleHost(h), h.User)
+		}
+	}
+	return buffer.Bytes()
+}
+
+// ~/.ssh/config 格式, 只包含有公共IP的实例
+func sshConfig(hosts []inventoryHost) []byte {
+	var buffer bytes.Buffer
+	for _, h := range hosts {
+		if h.PublicIp == "" || strings.Contains(strings.ToLower(h.OperatingSystem), "windows") {
+			continue
+		}
+		fmt.Fprintf(&buffer, "# %s %s\nHost %s\n    HostName %s\n    User %s\n", h.Account, h.InstanceId, h.Alias, h.PublicIp, h.User)
+		if sshIdentityFile != "" {
+			fmt.Fprintf(&buffer, "    IdentityFile %s\n", sshIdentityFile)
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes()
+}
+
+func csvInventory(hosts []inventoryHost) ([]byte, error) {
+	var buffer bytes.Buffer
+	w := csv.NewWriter(&buffer)
+	w.Write([]string{"account", "region", "name", "instance_id", "state", "availability_domain", "shape", "operating_system", "public_ip", "private_ip", "ssh_user"})
+	for _, h := range hosts {
+		w.Write([]string{h.Account, h.Region, h.Name, h.InstanceId, h.State, h.AvailabilityDomain, h.Shape, h.OperatingSystem, h.PublicIp, h.PrivateIp, h.User})
+	}
+	w.Flush()
+	return buffer.Bytes(), w.Error()
+}
+
+// 返回值 sum: 创建实例总数; num: 创建成功的个数; abortAccount: 是否停止当前账号的其他实例模版
+func LaunchInstances(ads []identity.AvailabilityDomain) (sum, num int32, abortAccount bool) {
+	/* 创建实例的几种情况
+	 * 1. 设置了 availabilityDomain 参数，即在设置的可用性域中创建 sum 个实例。
+	 * 2. 没有设置 availabilityDomain 但是设置了 each 参数。即在获取的每个可用性域中创建 each 个实例，创建的实例总数 sum =  each * adCount。
+	 * 3. 没有设置 availabilityDomain 且没有设置 each 参数，即在获取到的可用性域中创建的实例总数为 sum。
+	 * 4. 设置了 source=bootVolume，即使用指定的引导卷在引导卷所在的可用性域中创建 1 个实例。
+	 */
+
+	// 使用已有的引导卷创建实例
+	var bootVolume *core.BootVolume
+	if strings.EqualFold(instance.Source, "bootVolume") {
+		fmt.Println("正在获取引导卷...")
+		volume, err := findBootVolume(ads, instance.BootVolume)
+		if err != nil {
+			printlnErr("获取引导卷失败", err.Error())
+			return
+		}
+		fmt.Println("引导卷:", *volume.DisplayName)
+		bootVolume = &volume
+		instance.AvailabilityDomain = *volume.AvailabilityDomain
+		instance.Each = 0
+		instance.Sum = 1
+	}
+
+	//可用性域数量
+	var adCount int32 = int32(len(ads))
+	adName := common.String(instance.AvailabilityDomain)
+	each := instance.Each
+	sum = instance.Sum
+
+	// 没有设置可用性域并且没有设置each时，才有用。
+	var usableAds = make([]identity.AvailabilityDomain, 0)
+
+	//可用性域不固定，即没有提供 availabilityDomain 参数
+	var AD_NOT_FIXED bool = false
+	var EACH_AD = false
+	if adName == nil || *adName == "" {
+		AD_NOT_FIXED = true
+		if each > 0 {
+			EACH_AD = true
+			sum = each * adCount
+		} else {
+			EACH_AD = false
+			usableAds = ads
+		}
+	}
+
+	name := instance.InstanceDisplayName
+	if name == "" {
+		name = time.Now().Format("instance-20060102-1504")
+	}
+	displayName := common.String(name)
+	if sum > 1 {
+		displayName = common.String(name + "-1")
+	}
+	// Get a image.
+	fmt.Println("正在获取系统镜像...")
+	var image core.Image
+	var err error
+	if bootVolume != nil {
+		// 引导卷的源镜像可能已被删除, 仅用于获取Shape和显示
+		var resp core.GetImageResponse
+		resp, err = computeClient.GetImage(ctx, core.GetImageRequest{
+			ImageId:         bootVolume.ImageId,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		image = resp.Image
+		if err != nil || bootVolume.ImageId == nil {
+			image = core.Image{DisplayName: common.String("引导卷 " + *bootVolume.DisplayName)}
+		}
+	} else {
+		image, err = GetImage(ctx, computeClient)
+		if err != nil {
+			printlnErr("获取系统镜像失败", err.Error())
+			return
+		}
+	}
+	if image.Id != nil {
+		fmt.Println("系统镜像:", *image.DisplayName, *image.Id)
+	} else {
+		fmt.Println("系统镜像:", *image.DisplayName)
+	}
+
+	// 弹性实例配置档位, 从第一档开始尝试, 创建失败后依次降档
+	var ladder []shapeRung
+	var rungIndex int
+	var usage freeTierUsage // 账号中已使用的 Always Free 资源
+	checkA1FreeTier := !instance.AllowPaid && isA1Shape(instance.Shape)
+	if !instance.AllowPaid {
+		fmt.Println("正在统计 Always Free 资源...")
+		usage, err = getFreeTierUsage(ads)
+		if err != nil {
+			printlnErr("统计 Always Free 资源失败", err.Error())
+			return
+		}
+	}
+	if instance.ShapeLadder != "" {
+		if !strings.Contains(strings.ToLower(instance.Shape), "flex") {
+			printlnErr("shapeLadder 仅支持弹性实例", instance.Shape)
+			return
+		}
+		ladder, err = parseShapeLadder(instance.ShapeLadder)
+		if err != nil {
+			printlnErr("解析 shapeLadder 失败", err.Error())
+			return
+		}
+		rungIndex = -1
+		if !nextShapeRung(ladder, &rungIndex, usage, checkA1FreeTier) {
+			printlnErr("没有符合 Always Free 限额的配置档位", fmt.Sprintf("已使用 OCPU: %g 内存: %g", usage.A1Ocpus, usage.A1MemoryInGBs))
+			return
+		}
+	}
+
+	var shape core.Shape
+	if len(ladder) > 0 {
+		// OCPU 和内存使用当前配置档位, 不需要查询
+		shape.Shape = common.String(instance.Shape)
+		useShapeRung(nil, &shape, ladder[rungIndex])
+	} else {
+		shape, err = getTemplateShape(image)
+		if err != nil {
+			printlnErr("获取Shape信息失败", err.Error())
+			return
+		}
+	}
+
+	// create the launch instance request
+	request, _, err := buildLaunchInstanceRequest(image, shape, bootVolume)
+	if err != nil {
+		printlnErr("创建实例请求失败", err.Error())
+		return
+	}
+	request.DisplayName = displayName
+
+	pacer := newLaunchPacer()
+
+	SKIP_RETRY_MAP := make(map[int32]bool)
+	var usableAdsTemp = make([]identity.AvailabilityDomain, 0)
+
+	retry := instance.Retry // 重试次数
+	if retry < 0 && unlimitedRetryCap > 0 {
+		retry = unlimitedRetryCap
+	}
+	var failTimes int32 = 0 // 失败次数
+
+	// 记录尝试创建实例的次数
+	var runTimes int32 = 0
+
+	var adIndex int32 = 0 // 当前可用性域下标
+	var pos int32 = 0     // for 循环次数
+	var SUCCESS = false   // 创建是否成功
+
+	var startTime = time.Now()
+
+	var bootVolumeSize float64
+	var newStorageInGBs int64 // 新增的块存储大小, 使用已有的引导卷时为 0
+	if bootVolume != nil {
+		bootVolumeSize = float64(*bootVolume.SizeInGBs)
+	} else if instance.BootVolumeSizeInGBs > 0 {
+		bootVolumeSize = float64(instance.BootVolumeSizeInGBs)
+		newStorageInGBs = instance.BootVolumeSizeInGBs
+	} else {
+		bootVolumeSize = math.Round(float64(*image.SizeInMBs) / float64(1024))
+		newStorageInGBs = int64(bootVolumeSize)
+	}
+	// 查询服务限制, 跳过没有可用额度的可用性域
+	limitName := getLimitName(*shape.Shape)
+	// 创建一个实例需要的服务限制额度
+	limitNeeded := func(ocpus float32) int64 {
+		if isMicroShape(*shape.Shape) || ocpus <= 1 {
+			return 1
+		}
+		return int64(math.Ceil(float64(ocpus)))
+	}
+	// 设置了配置档位时按最小的档位检查, 当前档位额度不足时还可以降档
+	needed := limitNeeded(*shape.Ocpus)
+	for _, rung := range ladder {
+		if n := limitNeeded(rung.Ocpus); n < needed {
+			needed = n
+		}
+	}
+	var limitInfo string
+	if limitName != "" {
+		fmt.Println("正在查询服务限制...")
+		availability, err := getLimitAvailability(limitName, ads)
+		if err != nil {
+			printlnErr("查询服务限制失败", err.Error())
+		} else {
+			var usable []identity.AvailabilityDomain
+			var infos []string
+			for _, ad := range ads {
+				available := availability[*ad.Name]
+				infos = append(infos, fmt.Sprintf("%s: %d", adShortName(*ad.Name), available))
+				if available >= needed && (AD_NOT_FIXED || *ad.Name == *adName) {
+					usable = append(usable, ad)
+				}
+			}
+			limitInfo = fmt.Sprintf("%s 可用额度 %s", limitName, strings.Join(infos, ", "))
+			fmt.Println(limitInfo)
+			if len(usable) == 0 {
+				printlnErr("服务限制额度已用完, 已取消创建", limitInfo)
+				if !isDryRun() {
+					sendMessage(fmt.Sprintf("[%s]", oracleSectionName), "服务限制额度已用完, 已取消创建\n"+limitInfo)
+				}
+				return
+			}
+			if AD_NOT_FIXED {
+				ads = usable
+				adCount = int32(len(ads))
+				if EACH_AD {
+					sum = each * adCount
+				} else {
+					usableAds = ads
+				}
+			}
+		}
+	}
+	// 创建失败并返回 LimitExceeded 时重新查询服务限制, 额度已用完返回 true
+	limitExhausted := func(err error) bool {
+		servErr, ok := common.IsServiceError(err)
+		if limitName == "" || !ok || !strings.EqualFold(servErr.GetCode(), "LimitExceeded") {
+			return false
+		}
+		availability, err := getLimitAvailability(limitName, ads)
+		if err != nil {
+			printlnErr("查询服务限制失败", err.Error())
+			return false
+		}
+		// 按当前配置档位检查, 当前档位额度不足时还可以降到后面的档位
+		needed := limitNeeded(*shape.Ocpus)
+		if len(ladder) > 0 {
+			for _, rung := range ladder[rungIndex:] {
+				if n := limitNeeded(rung.Ocpus); n < needed {
+					needed = n
+				}
+			}
+		}
+		for _, available := range availability {
+			if available >= needed {
+				return false
+			}
+		}
+		printf("\033[1;31m[%s] 服务限制 %s 额度已用完, 停止创建\033[0m\n", oracleSectionName, limitName)
+		if !isDryRun() {
+			sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("服务限制 %s 额度已用完, 停止创建", limitName))
+		}
+		return true
+	}
+
+	if !instance.AllowPaid {
+		if items := usage.exceeded(*shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, newStorageInGBs, sum); len(items) > 0 {
+			if strings.EqualFold(instance.FreeTierGuard, "warn") {
+				printf("\033[1;33m[%s] 警告: 创建后将超出 Always Free 限额, 可能产生费用. %s\033[0m\n", oracleSectionName, strings.Join(items, ", "))
+			} else {
+				printlnErr("创建后将超出 Always Free 限额, 已取消创建 (设置 allowPaid=true 跳过检查)", strings.Join(items, ", "))
+				if !isDryRun() {
+					sendMessage(fmt.Sprintf("[%s]", oracleSectionName), "创建后将超出 Always Free 限额, 已取消创建\n"+strings.Join(items, "\n"))
+				}
+				return
+			}
+		}
+	}
+
+	// 创建成功后的就绪检查
+	probes, err := parseReadinessProbes(instance.Readiness)
+	if err != nil {
+		printlnErr("解析 readiness 失败", err.Error())
+		return
+	}
+	readinessTimeout := time.Duration(instance.ReadinessTimeout) * time.Second
+	if readinessTimeout <= 0 {
+		readinessTimeout = 10 * time.Minute
+	}
+
+	if isDryRun() {
+		printDryRun(request, ads, AD_NOT_FIXED, sum)
+		sum = 0
+		return
+	}
+	// 返回前等待后台的就绪检查完成, 避免程序退出或切换账号后丢失检查结果
+	var readinessWait sync.WaitGroup
+	defer func() {
+		if len(probes) > 0 && num > 0 {
+			printf("\033[1;36m[%s] 正在等待就绪检查完成...\033[0m\n", oracleSectionName)
+		}
+		readinessWait.Wait()
+	}()
+	defer func() {
+		if num < sum {
+			event := hookEvent{
+				Account:            oracleSectionName,
+				Region:             oracle.Region,
+				Template:           instanceSectionName,
+				Name:               name,
+				AvailabilityDomain: instance.AvailabilityDomain,
+				Shape:              *shape.Shape,
+				Message:            fmt.Sprintf("创建实例总数: %d, 成功 %d, 失败 %d", sum, num, sum-num),
+			}
+			runHook(instance, hookOnFailure, event)
+		}
+	}()
+
+	// 创建时间段, 不在时间段内时暂停尝试
+	schedule, err := newLaunchSchedule(instance.LaunchWindow, instance.LaunchCron)
+	if err != nil {
+		printlnErr("解析创建时间段失败", err.Error())
+		return
+	}
+	if instance.StartAt != "" {
+		startAt, err := time.ParseInLocation("2006-01-02 15:04", instance.StartAt, time.Local)
+		if err != nil {
+			printlnErr("解析 startAt 失败", err.Error())
+			return
+		}
+		if d := time.Until(startAt); d > 0 {
+			printf("\033[1;36m[%s] 将在 %s 开始创建\033[0m\n", oracleSectionName, instance.StartAt)
+			time.Sleep(d)
+		}
+	}
+	schedule.wait()
+	startTime = time.Now()
+
+	printf("\033[1;36m[%s] 开始创建 %s 实例, OCPU: %g 内存: %g 引导卷: %g %s\033[0m\n", oracleSectionName, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, limitInfo)
+	if EACH {
+		text := fmt.Sprintf("正在尝试创建第 %d 个实例...⏳\n区域: %s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d", pos+1, oracle.Region, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum)
+		if limitInfo != "" {
+			text += "\n服务限制: " + limitInfo
+		}
+		_, err := sendMessage("", text)
+		if err != nil {
+			printlnErr("Telegram 消息提醒发送失败", err.Error())
+		}
+	}
+
+	// 创建实例成功后获取公共IP并发送消息提醒
+	reportSuccess := func(pos int32, ins core.Instance) {
+		duration := fmtDuration(time.Since(startTime))
+
+		extraInfo := fmt.Sprintf("\n系统镜像: %s", *image.DisplayName)
+		if len(ladder) > 0 {
+			extraInfo += fmt.Sprintf("\n配置档位: %s (第 %d 档)", ladder[rungIndex], rungIndex+1)
+			printf("\033[1;32m[%s] 第 %d 个实例配置档位: %s (第 %d 档)\033[0m\n", oracleSectionName, pos+1, ladder[rungIndex], rungIndex+1)
+		}
+		usage.add(*shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, newStorageInGBs, 1)
+		if bootVolume == nil {
+			if err := saveImagePin(image); err != nil {
+				printlnErr("保存系统镜像记录失败", err.Error())
+			}
+		}
+
+		printf("\033[1;32m[%s] 第 %d 个实例抢到了🎉, 正在启动中请稍等...⌛️ \033[0m\n", oracleSectionName, pos+1)
+		var msg Message
+		var msgErr error
+		var text string
+		if EACH {
+			text = fmt.Sprintf("第 %d 个实例抢到了🎉, 正在启动中请稍等...⌛️\n区域: %s\n实例名称: %s\n公共IP: 获取中...⏳\n可用性域:%s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时: %s", pos+1, oracle.Region, *ins.DisplayName, *ins.AvailabilityDomain, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration) + extraInfo
+			msg, msgErr = sendMessage("", text)
+		}
+		// 获取实例公共IP
+		var strIps string
+		var status, readiness string
+		var sentText string // 发送的消息内容, 就绪检查完成后编辑消息时使用
+		ips, err := getInstancePublicIps(ins.Id)
+		if err != nil {
+			printf("\033[1;32m[%s] 第 %d 个实例抢到了🎉, 但是启动失败❌ 错误信息: \033[0m%s\n", oracleSectionName, pos+1, err.Error())
+			text = fmt.Sprintf("第 %d 个实例抢到了🎉, 但是启动失败❌实例已被终止😔\n区域: %s\n实例名称: %s\n可用性域:%s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时: %s", pos+1, oracle.Region, *ins.DisplayName, *ins.AvailabilityDomain, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
+		} else {
+			strIps = strings.Join(ips, ",")
+			status = "启动成功✅"
+			if len(probes) > 0 {
+				readiness = "\n就绪检查: 正在检查⏳"
+			}
+			printf("\033[1;32m[%s] 第 %d 个实例抢到了🎉, %s. 实例名称: %s, 公共IP: %s\033[0m%s\n", oracleSectionName, pos+1, status, *ins.DisplayName, strIps, readiness)
+			text = fmt.Sprintf("第 %d 个实例抢到了🎉, %s\n区域: %s\n实例名称: %s\n公共IP: %s\n可用性域:%s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时: %s", pos+1, status, oracle.Region, *ins.DisplayName, strIps, *ins.AvailabilityDomain, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
+		}
+		text += extraInfo
+		sentText = text
+		if EACH {
+			if msgErr != nil {
+				msg, msgErr = sendMessage("", text+readiness)
+			} else {
+				_, msgErr = editMessage(msg.MessageId, "", text+readiness)
+			}
+		}
+		if err != nil {
+			runHook(instance, hookOnFailure, newHookEvent(ins, nil, err.Error()))
+		} else {
+			updateDnsRecords(instance, ins, ips)
+			if len(probes) > 0 {
+				// 就绪检查可能需要几分钟, 在后台执行, 不阻塞创建下一个实例
+				printf("\033[1;36m[%s] 第 %d 个实例正在后台进行就绪检查...\033[0m\n", oracleSectionName, pos+1)
+				var messageId int
+				if msgErr == nil {
+					messageId = msg.MessageId
+				}
+				readinessWait.Add(1)
+				go func(template Instance, event hookEvent, ins core.Instance, ips []string, messageId int, text string) {
+					defer readinessWait.Done()
+					reportReadiness(computeClient, template, event, ins, ips, probes, readinessTimeout, EACH, messageId, text)
+				}(instance, newHookEvent(ins, ips, status), ins, ips, messageId, sentText)
+			} else {
+				runHook(instance, hookOnSuccess, newHookEvent(ins, ips, status))
+			}
+		}
+
+		if err == nil && instance.BootVolumeVpusPerGB > 0 {
+			if err := setInstanceBootVolumeVpus(ins, instance.BootVolumeVpusPerGB); err != nil {
+				printlnErr("修改引导卷性能失败", err.Error())
+			}
+		}
+		if err == nil {
+			// 以较小的配置创建成功后, 在后台自动升级到目标配置
+			var target *shapeRung
+			if instance.GrowTo != "" {
+				if rungs, growErr := parseShapeLadder(instance.GrowTo); growErr != nil {
+					printlnErr("解析 growTo 失败", growErr.Error())
+				} else {
+					target = &rungs[0]
+				}
+			} else if len(ladder) > 0 && rungIndex > 0 && instance.LadderUpsize {
+				target = &ladder[0]
+			}
+			if target != nil && !instance.AllowPaid && isA1Shape(*shape.Shape) {
+				// 升级后不能超出 Always Free 限额
+				capped, capErr := capGrowTarget(ads, *shape.Ocpus, *shape.MemoryInGBs, *target)
+				if capErr != nil {
+					printlnErr("获取 Always Free 资源使用情况失败, 不自动升级", capErr.Error())
+					target = nil
+				} else {
+					target = &capped
+				}
+			}
+			if target != nil && (target.Ocpus > *shape.Ocpus || target.MemoryInGBs > *shape.MemoryInGBs) {
+				startGrowJob(ins, *target, time.Duration(instance.GrowInterval)*time.Second)
+			}
+		}
+		if len(ladder) > 0 {
+			// 下一个实例重新从第一档开始尝试
+			rungIndex = -1
+			if !nextShapeRung(ladder, &rungIndex, usage, checkA1FreeTier) {
+				rungIndex = 0
+			}
+			useShapeRung(&request, &shape, ladder[rungIndex])
+		}
+	}
+
+	if AD_NOT_FIXED && !EACH_AD && instance.Parallel {
+		// 同时在所有可用的可用性域中尝试创建
+		for pos < sum {
+			schedule.wait()
+			runTimes++
+			printf("\033[1;36m[%s] 正在尝试在 %d 个可用性域中同时创建第 %d 个实例\033[0m\n", oracleSectionName, len(usableAds), pos+1)
+			printf("\033[1;36m[%s] 当前尝试次数: %d \033[0m\n", oracleSectionName, runTimes)
+			batch := usableAds
+			usableAdsTemp = nil
+			created, errs := launchInParallel(request, batch)
+
+			var lastErr error
+			for _, ins := range created {
+				if pos >= sum {
+					// 多个可用性域同时创建成功, 超出创建个数的实例立即终止
+					terminateSurplusInstance(ins)
+					continue
+				}
+				num++
+				if sum > 1 {
+					newName := fmt.Sprintf("%s-%d", name, pos+1)
+					if *ins.DisplayName != newName {
+						if _, err := updateInstance(ins.Id, &newName, nil, nil, nil, nil); err == nil {
+							ins.DisplayName = common.String(newName)
+						}
+					}
+				}
+				reportSuccess(pos, ins)
+				pos++
+			}
+
+			for i, err := range errs {
+				if err == nil {
+					usableAdsTemp = append(usableAdsTemp, batch[i])
+					continue
+				}
+				if errors.Is(err, errLaunchUnknown) {
+					// 实例可能已经创建, 继续创建可能超出创建个数
+					printf("\033[1;31m[%s] %s, 停止创建, 请在控制台确认实例状态\033[0m\n", oracleSectionName, err.Error())
+					sendMessage("", fmt.Sprintf("第 %d 个实例%s, 已停止创建, 请在控制台确认实例状态\n区域: %s\n可用性域: %s", pos+1, err.Error(), oracle.Region, *batch[i].Name))
+					return
+				}
+				lastErr = err
+				errInfo := err.Error()
+				if servErr, ok := common.IsServiceError(err); ok {
+					errInfo = servErr.GetMessage()
+				}
+				policy := matchErrorPolicy(err)
+				printf("\033[1;31m[%s] 创建失败, AD: %s, Error: \033[0m%s\n", oracleSectionName, *batch[i].Name, errInfo)
+				if policy.notify {
+					text := fmt.Sprintf("第 %d 个实例创建失败\n错误信息: %s\n区域: %s\n可用性域: %s\n尝试次数: %d", pos+1, errInfo, oracle.Region, *batch[i].Name, runTimes)
+					sendMessage("", text)
+				}
+				if policy.action == errorActionAbortTemplate || policy.action == errorActionAbortAccount {
+					printf("\033[1;31m[%s] 命中错误策略 [%s], 停止创建\033[0m\n", oracleSectionName, policy.name)
+					abortAccount = policy.action == errorActionAbortAccount
+					return
+				}
+				if limitExhausted(err) {
+					return
+				}
+				if policy.action == errorActionRetry {
+					usableAdsTemp = append(usableAdsTemp, batch[i])
+				}
+			}
+
+			if len(created) > 0 {
+				// 创建成功, 重置变量
+				usableAds = ads
+				failTimes = 0
+				runTimes = 0
+				startTime = time.Now()
+				if pos < sum {
+					pacer.wait(nil)
+				}
+				continue
+			}
+
+			usableAds = usableAdsTemp
+			failTimes++
+			if len(ladder) > 0 && nextShapeRung(ladder, &rungIndex, usage, checkA1FreeTier) {
+				useShapeRung(&request, &shape, ladder[rungIndex])
+				printf("\033[1;36m[%s] 切换配置档位: %s (第 %d 档)\033[0m\n", oracleSectionName, ladder[rungIndex], rungIndex+1)
+			}
+			if len(usableAds) == 0 || (retry >= 0 && failTimes > retry) {
+				// 没有可用的可用性域或失败次数达到重试次数, 放弃当前实例
+				printf("\033[1;31m[%s] 第 %d 个实例创建失败了❌\033[0m\n", oracleSectionName, pos+1)
+				usableAds = ads
+				failTimes = 0
+				runTimes = 0
+				startTime = time.Now()
+				pos++
+			}
+			if pos < sum {
+				pacer.wait(lastErr)
+			}
+		}
+		return
+	}
+
+	for pos < sum {
+
+		if AD_NOT_FIXED {
+			if EACH_AD {
+				if pos%each == 0 && failTimes == 0 {
+					adName = ads[adIndex].Name
+					adIndex++
+				}
+			} else {
+				if SUCCESS {
+					adIndex = 0
+				}
+				if adIndex >= adCount {
+					adIndex = 0
+				}
+				//adName = ads[adIndex].Name
+				adName = usableAds[adIndex].Name
+				adIndex++
+			}
+		}
+
+		schedule.wait()
+		runTimes++
+		printf("\033[1;36m[%s] 正在尝试创建第 %d 个实例, AD: %s\033[0m\n", oracleSectionName, pos+1, *adName)
+		printf("\033[1;36m[%s] 当前尝试次数: %d \033[0m\n", oracleSectionName, runTimes)
+		request.AvailabilityDomain = adName
+		createResp, err := computeClient.LaunchInstance(ctx, request)
+
+		if err == nil {
+			// 创建实例成功
+			SUCCESS = true
+			num++ //成功个数+1
+
+			reportSuccess(pos, createResp.Instance)
+
+			pacer.wait(nil)
+
+			displayName = common.String(fmt.Sprintf("%s-%d", name, pos+1))
+			request.DisplayName = displayName
+
+		} else {
+			// 创建实例失败
+			SUCCESS = false
+			// 错误信息
+			errInfo := err.Error()
+			// 是否跳过重试
+			SKIP_RETRY := false
+
+			//isRetryable := common.IsErrorRetryableByDefault(err)
+			//isNetErr := common.IsNetworkError(err)
+			servErr, isServErr := common.IsServiceError(err)
+			if isServErr {
+				errInfo = servErr.GetMessage()
+			}
+
+			// API Errors: https://docs.cloud.oracle.com/Content/API/References/apierrors.htm
+			// 根据错误策略表决定如何处理
+			policy := matchErrorPolicy(err)
+
+			if policy.action != errorActionRetry {
+				// 不可重试
+				duration := fmtDuration(time.Since(startTime))
+				printf("\033[1;31m[%s] 第 %d 个实例创建失败了❌, 错误信息: \033[0m%s\n", oracleSectionName, pos+1, errInfo)
+				if EACH || policy.notify {
+					text := fmt.Sprintf("第 %d 个实例创建失败了❌\n错误信息: %s\n区域: %s\n可用性域: %s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d\n尝试次数: %d\n耗时:%s", pos+1, errInfo, oracle.Region, *adName, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum, runTimes, duration)
+					sendMessage("", text)
+				}
+
+				SKIP_RETRY = true
+				if AD_NOT_FIXED && !EACH_AD {
+					SKIP_RETRY_MAP[adIndex-1] = true
+				}
+
+			} else {
+				// 可重试
+				printf("\033[1;31m[%s] 创建失败, Error: \033[0m%s\n", oracleSectionName, errInfo)
+				if policy.notify {
+					text := fmt.Sprintf("第 %d 个实例创建失败, 继续尝试...⏳\n错误信息: %s\n区域: %s\n可用性域: %s\n尝试次数: %d", pos+1, errInfo, oracle.Region, *adName, runTimes)
+					sendMessage("", text)
+				}
+
+				SKIP_RETRY = false
+				if AD_NOT_FIXED && !EACH_AD {
+					SKIP_RETRY_MAP[adIndex-1] = false
+				}
+			}
+
+			if policy.action == errorActionAbortTemplate || policy.action == errorActionAbortAccount {
+				printf("\033[1;31m[%s] 命中错误策略 [%s], 停止创建\033[0m\n", oracleSectionName, policy.name)
+				abortAccount = policy.action == errorActionAbortAccount
+				return
+			}
+			if limitExhausted(err) {
+				return
+			}
+
+			// 一轮可用性域尝试结束后仍然失败, 降低配置档位
+			if len(ladder) > 0 && (!AD_NOT_FIXED || EACH_AD || adIndex >= adCount) {
+				if nextShapeRung(ladder, &rungIndex, usage, checkA1FreeTier) {
+					useShapeRung(&request, &shape, ladder[rungIndex])
+					printf("\033[1;36m[%s] 切换配置档位: %s (第 %d 档)\033[0m\n", oracleSectionName, ladder[rungIndex], rungIndex+1)
+				}
+			}
+
+			pacer.wait(err)
+
+			if AD_NOT_FIXED {
+				if !EACH_AD {
+					if adIndex < adCount {
+						// 没有设置可用性域，且没有设置each。即在获取到的每个可用性域里尝试创建。当前使用的可用性域不是最后一个，继续尝试。
+						continue
+					} else {
+						// 当前使用的可用性域是最后一个，判断失败次数是否达到重试次数，未达到重试次数继续尝试。
+						failTimes++
+
+						for index, skip := range SKIP_RETRY_MAP {
+							if !skip {
+								usableAdsTemp = append(usableAdsTemp, usableAds[index])
+							}
+						}
+
+						// 重新设置 usableAds
+						usableAds = usableAdsTemp
+						adCount = int32(len(usableAds))
+
+						// 重置变量
+						usableAdsTemp = nil
+						for k := range SKIP_RETRY_MAP {
+							delete(SKIP_RETRY_MAP, k)
+						}
+
+						// 判断是否需要重试
+						if (retry < 0 || failTimes <= retry) && adCount > 0 {
+							continue
+						}
+					}
+
+					adIndex = 0
+
+				} else {
+					// 没有设置可用性域，且设置了each，即在每个域创建each个实例。判断失败次数继续尝试。
+					failTimes++
+					if (retry < 0 || failTimes <= retry) && !SKIP_RETRY {
+						continue
+					}
+				}
+
+			} else {
+				//设置了可用性域，判断是否需要重试
+				failTimes++
+				if (retry < 0 || failTimes <= retry) && !SKIP_RETRY {
+					continue
+				}
+			}
+
+		}
+
+		// 重置变量
+		usableAds = ads
+		adCount = int32(len(usableAds))
+		usableAdsTemp = nil
+		for k := range SKIP_RETRY_MAP {
+			delete(SKIP_RETRY_MAP, k)
+		}
+
+		// 成功或者失败次数达到重试次数，重置失败次数为0
+		failTimes = 0
+
+		// 重置尝试创建实例次数
+		runTimes = 0
+		startTime = time.Now()
+
+		// for 循环次数+1
+		pos++
+
+		if pos < sum && EACH {
+			text := fmt.Sprintf("正在尝试创建第 %d 个实例...⏳\n区域: %s\n实例配置: %s\nOCPU计数: %g\n内存(GB): %g\n引导卷(GB): %g\n创建个数: %d", pos+1, oracle.Region, *shape.Shape, *shape.Ocpus, *shape.MemoryInGBs, bootVolumeSize, sum)
+			sendMessage("", text)
+		}
+	}
+	return
+}
+
+// 解析错误策略, 每个键为一条规则, 格式: 状态码|错误码|错误信息(正则)|动作[+notify]
+// 状态码可以是单个值 (400) 或范围 (400-405), 状态码、错误码和错误信息为空表示匹配任意值
+func loadErrorPolicy(sec *ini.Section) (rules []errorPolicyRule, err error) {
+	for _, key := range sec.Keys() {
+		fields := strings.Split(key.Value(), "|")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("规则 %s 格式错误, 应为 状态码|错误码|错误信息|动作", key.Name())
+		}
+		rule := errorPolicyRule{name: key.Name(), code: strings.TrimSpace(fields[1])}
+
+		status := strings.TrimSpace(fields[0])
+		if status != "" && status != "*" {
+			bounds := strings.SplitN(status, "-", 2)
+			rule.minStatus, err = strconv.Atoi(strings.TrimSpace(bounds[0]))
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s 状态码错误: %s", key.Name(), status)
+			}
+			rule.maxStatus = rule.minStatus
+			if len(bounds) == 2 {
+				rule.maxStatus, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+				if err != nil {
+					return nil, fmt.Errorf("规则 %s 状态码错误: %s", key.Name(), status)
+				}
+			}
+		}
+
+		if pattern := strings.TrimSpace(fields[2]); pattern != "" {
+			rule.message, err = regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s 错误信息正则表达式错误: %s", key.Name(), err.Error())
+			}
+		}
+
+		for _, action := range strings.Split(strings.TrimSpace(fields[3]), "+") {
+			switch action = strings.ToLower(strings.TrimSpace(action)); action {
+			case errorActionRetry, errorActionSkipAD, errorActionAbortTemplate, errorActionAbortAccount:
+				rule.action = action
+			case "notify":
+				rule.notify = true
+			default:
+				return nil, fmt.Errorf("规则 %s 动作错误: %s", key.Name(), action)
+			}
+		}
+		if rule.action == "" {
+			rule.action = errorActionRetry
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// 返回与错误匹配的第一条规则, 没有匹配的规则时继续重试
+func matchErrorPolicy(err error) errorPolicyRule {
+	var status int
+	var code, message string
+	if servErr, ok := common.IsServiceError(err); ok {
+		status = servErr.GetHTTPStatusCode()
+		code = servErr.GetCode()
+		message = servErr.GetMessage()
+	} else if err != nil {
+		message = err.Error()
+	}
+	rules := append(append([]errorPolicyRule{}, errorPolicyRules...), defaultErrorPolicyRules...)
+	for _, rule := range rules {
+		if rule.minStatus > 0 && (status < rule.minStatus || status > rule.maxStatus) {
+			continue
+		}
+		if rule.code != "" && !strings.EqualFold(rule.code, code) {
+			continue
+		}
+		if rule.message != nil && !rule.message.MatchString(message) {
+			continue
+		}
+		return rule
+	}
+	return errorPolicyRule{name: "default", action: errorActionRetry}
+}
+
+// 解析弹性实例配置档位, 格式: 4/24,2/12,1/6 (OCPU/内存GB)
+func parseShapeLadder(str string) (ladder []shapeRung, err error) {
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, "/")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("配置档位格式错误: %s", item)
+		}
+		ocpus, err1 := strconv.ParseFloat(strings.TrimSpace(fields[0]), 32)
+		memory, err2 := strconv.ParseFloat(strings.TrimSpace(fields[1]), 32)
+		if err1 != nil || err2 != nil || ocpus <= 0 || memory <= 0 {
+			return nil, fmt.Errorf("配置档位格式错误: %s", item)
+		}
+		ladder = append(ladder, shapeRung{Ocpus: float32(ocpus), MemoryInGBs: float32(memory)})
+	}
+	if len(ladder) == 0 {
+		err = errors.New("配置档位为空")
+	}
+	return
+}
+
+// 切换到下一个配置档位, 最后一档之后回到第一档。checkA1FreeTier 为 true 时跳过超出 A1 Always Free 限额的档位。
+// 切换成功时更新 index, 没有符合条件的档位时返回 false。
+func nextShapeRung(ladder []shapeRung, index *int, usage freeTierUsage, checkA1FreeTier bool) bool {
+	for i := 1; i <= len(ladder); i++ {
+		next := (*index + i) % len(ladder)
+		if *index < 0 {
+			next = i - 1
+		}
+		rung := ladder[next]
+		if checkA1FreeTier &&
+			(usage.A1Ocpus+rung.Ocpus > freeTierA1Ocpus || usage.A1MemoryInGBs+rung.MemoryInGBs > freeTierA1MemoryInGBs) {
+			continue
+		}
+		*index = next
+		return true
+	}
+	return false
+}
+
+// 使用配置档位的 OCPU 和内存, request 为 nil 时只更新 shape
+func useShapeRung(request *core.LaunchInstanceRequest, shape *core.Shape, rung shapeRung) {
+	shape.Ocpus = common.Float32(rung.Ocpus)
+	shape.MemoryInGBs = common.Float32(rung.MemoryInGBs)
+	if request != nil && request.ShapeConfig != nil {
+		config := *request.ShapeConfig
+		config.Ocpus = common.Float32(rung.Ocpus)
+		config.MemoryInGBs = common.Float32(rung.MemoryInGBs)
+		request.ShapeConfig = &config
+	}
+}
+
+func isA1Shape(shape string) bool {
+	return strings.Contains(strings.ToUpper(shape), ".A1.")
+}
+
+func isMicroShape(shape string) bool {
+	return strings.EqualFold(shape, "VM.Standard.E2.1.Micro")
+}
+
+// 统计账号中已使用的 Always Free 资源: 未终止的 A1 实例的 OCPU 和内存、E2.1.Micro 实例个数、引导卷和块存储卷总大小
+func getFreeTierUsage(ads []identity.AvailabilityDomain) (usage freeTierUsage, err error) {
+	var ins []core.Instance
+	var nextPage *string
+	for {
+		ins, nextPage, err = ListInstances(ctx, computeClient, nextPage)
+		if err != nil {
+			return
+		}
+		for _, i := range ins {
+			if i.LifecycleState == core.InstanceLifecycleStateTerminating || i.LifecycleState == core.InstanceLifecycleStateTerminated {
+				continue
+			}
+			var ocpus, memoryInGBs float32
+			if i.ShapeConfig != nil && i.ShapeConfig.Ocpus != nil && i.ShapeConfig.MemoryInGBs != nil {
+				ocpus, memoryInGBs = *i.ShapeConfig.Ocpus, *i.ShapeConfig.MemoryInGBs
+			}
+			usage.add(*i.Shape, ocpus, memoryInGBs, 0, 1)
+		}
+		if nextPage == nil || len(ins) == 0 {
+			break
+		}
+	}
+
+	for _, ad := range ads {
+		var volumes []core.BootVolume
+		volumes, err = getBootVolumes(ad.Name)
+		if err != nil {
+			return
+		}
+		for _, volume := range volumes {
+			if volume.LifecycleState != core.BootVolumeLifecycleStateTerminating && volume.LifecycleState != core.BootVolumeLifecycleStateTerminated && volume.SizeInGBs != nil {
+				usage.StorageInGBs += *volume.SizeInGBs
+			}
+		}
+	}
+
+	req := core.ListVolumesRequest{
+		CompartmentId:   common.String(oracle.Tenancy),
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	for {
+		var resp core.ListVolumesResponse
+		resp, err = storageClient.ListVolumes(ctx, req)
+		if err != nil {
+			return
+		}
+		for _, volume := range resp.Items {
+			if volume.LifecycleState != core.VolumeLifecycleStateTerminating && volume.LifecycleState != core.VolumeLifecycleStateTerminated && volume.SizeInGBs != nil {
+				usage.StorageInGBs += *volume.SizeInGBs
+			}
+		}
+		if resp.OpcNextPage == nil {
+			break
+		}
+		req.Page = resp.OpcNextPage
+	}
+	return
+}
+
+func (u *freeTierUsage) add(shape string, ocpus, memoryInGBs float32, storageInGBs int64, count int32) {
+	if isA1Shape(shape) {
+		u.A1Ocpus += ocpus * float32(count)
+		u.A1MemoryInGBs += memoryInGBs * float32(count)
+	} else if isMicroShape(shape) {
+		u.MicroCount += count
+	}
+	u.StorageInGBs += storageInGBs * int64(count)
+}
+
+// 检查创建 count 个实例后是否超出 Always Free 限额, 返回超出的项目
+func (u freeTierUsage) exceeded(shape string, ocpus, memoryInGBs float32, storageInGBs int64, count int32) (items []string) {
+	if !isA1Shape(shape) && !isMicroShape(shape) {
+		items = append(items, fmt.Sprintf("%s 不是 Always Free 实例配置", shape))
+	}
+	after := u
+	after.add(shape, ocpus, memoryInGBs, storageInGBs, count)
+	if after.A1Ocpus > freeTierA1Ocpus {
+		items = append(items, fmt.Sprintf("A1 OCPU: %g > %g", after.A1Ocpus, freeTierA1Ocpus))
+	}
+	if after.A1MemoryInGBs > freeTierA1MemoryInGBs {
+		items = append(items, fmt.Sprintf("A1 内存(GB): %g > %g", after.A1MemoryInGBs, freeTierA1MemoryInGBs))
+	}
+	if after.MicroCount > freeTierMicroCount {
+		items = append(items, fmt.Sprintf("E2.1.Micro 实例个数: %d > %d", after.MicroCount, freeTierMicroCount))
+	}
+	if after.StorageInGBs > freeTierStorageInGBs {
+		items = append(items, fmt.Sprintf("块存储(GB): %d > %d", after.StorageInGBs, freeTierStorageInGBs))
+	}
+	return
+}
+
+// 实例配置对应的服务限制名称, 可以在实例模版中通过 limitName 指定
+// https://docs.oracle.com/en-us/iaas/Content/General/Concepts/servicelimits.htm#computelimits
+func getLimitName(shape string) string {
+	if instance.LimitName != "" {
+		return instance.LimitName
+	}
+	switch {
+	case isA1Shape(shape):
+		return "standard-a1-core-count"
+	case isMicroShape(shape):
+		return "standard-e2-micro-core-count"
+	}
+	return ""
+}
+
+// 查询每个可用性域中指定服务限制的可用额度。区域级别的服务限制, 每个可用性域返回相同的额度。
+func getLimitAvailability(limitName string, ads []identity.AvailabilityDomain) (availability map[string]int64, err error) {
+	availability = make(map[string]int64)
+	for _, ad := range ads {
+		req := limits.GetResourceAvailabilityRequest{
+			ServiceName:        common.String("compute"),
+			LimitName:          common.String(limitName),
+			CompartmentId:      common.String(oracle.Tenancy),
+			AvailabilityDomain: ad.Name,
+			RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
+		}
+		var resp limits.GetResourceAvailabilityResponse
+		resp, err = limitsClient.GetResourceAvailability(ctx, req)
+		if servErr, ok := common.IsServiceError(err); ok && servErr.GetHTTPStatusCode() == 400 {
+			// 不是可用性域级别的服务限制
+			req.AvailabilityDomain = nil
+			resp, err = limitsClient.GetResourceAvailability(ctx, req)
+			if err != nil {
+				return
+			}
+			for _, ad := range ads {
+				availability[*ad.Name] = getAvailable(resp.ResourceAvailability)
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+		availability[*ad.Name] = getAvailable(resp.ResourceAvailability)
+	}
+	return
+}
+
+func getAvailable(r limits.ResourceAvailability) int64 {
+	if r.Available != nil {
+		return *r.Available
+	}
+	if r.FractionalAvailability != nil {
+		return int64(*r.FractionalAvailability)
+	}
+	return 0
+}
+
+// 可用性域简称, 例如 xxxx:AP-TOKYO-1-AD-1 返回 AD-1
+func adShortName(name string) string {
+	if i := strings.LastIndex(name, "-AD-"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// 按 Always Free 剩余额度限制 A1 实例的升级目标, ocpus 和 memoryInGBs 为实例当前配置
+func capGrowTarget(ads []identity.AvailabilityDomain, ocpus, memoryInGBs float32, target shapeRung) (shapeRung, error) {
+	usage, err := getFreeTierUsage(ads)
+	if err != nil {
+		return target, err
+	}
+	// 已使用的资源包含该实例当前的配置
+	maxOcpus := freeTierA1Ocpus - usage.A1Ocpus + ocpus
+	maxMemoryInGBs := freeTierA1MemoryInGBs - usage.A1MemoryInGBs + memoryInGBs
+	if target.Ocpus > maxOcpus || target.MemoryInGBs > maxMemoryInGBs {
+		capped := shapeRung{Ocpus: float32(math.Min(float64(target.Ocpus), float64(maxOcpus))), MemoryInGBs: float32(math.Min(float64(target.MemoryInGBs), float64(maxMemoryInGBs)))}
+		printf("\033[1;33m[%s] 升级目标 %s 超出 Always Free 限额, 调整为 %s (设置 allowPaid=true 跳过检查)\033[0m\n", oracleSectionName, target, capped)
+		target = capped
+	}
+	return target, nil
+}
+
+// 在后台定时尝试将实例升级到目标配置, 同一实例只保留一个任务
+func startGrowJob(ins core.Instance, target shapeRung, interval time.Duration) {
+	if interval <= 0 {
+		interval = 300 * time.Second
+	}
+	job := &growJob{
+		account:    oracleSectionName,
+		client:     computeClient,
+		instanceId: *ins.Id,
+		name:       *ins.DisplayName,
+		target:     target,
+		interval:   interval,
+		status:     "等待中",
+		stop:       make(chan struct{}),
+	}
+	growJobsMutex.Lock()
+	if old, ok := growJobs[job.instanceId]; ok {
+		close(old.stop)
+	}
+	growJobs[job.instanceId] = job
+	growJobsMutex.Unlock()
+
+	printf("\033[1;36m[%s] 开始在后台将实例 %s 升级到 %s, 间隔 %s\033[0m\n", job.account, job.name, target, interval)
+	go job.run()
+}
+
+func (job *growJob) setStatus(status string) {
+	job.mutex.Lock()
+	job.status = status
+	job.mutex.Unlock()
+}
+
+// 返回尝试次数和状态
+func (job *growJob) progress() (int32, string) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	return job.attempts, job.status
+}
+
+func stopGrowJob(instanceId string) {
+	growJobsMutex.Lock()
+	defer growJobsMutex.Unlock()
+	if job, ok := growJobs[instanceId]; ok {
+		close(job.stop)
+		delete(growJobs, instanceId)
+	}
+}
+
+func (job *growJob) run() {
+	defer func() {
+		growJobsMutex.Lock()
+		if growJobs[job.instanceId] == job {
+			delete(growJobs, job.instanceId)
+		}
+		growJobsMutex.Unlock()
+	}()
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+	for {
+		if job.tryGrow() {
+			return
+		}
+		select {
+		case <-job.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 尝试升级一次, 返回 true 表示任务结束
+func (job *growJob) tryGrow() bool {
+	resp, err := job.client.GetInstance(ctx, core.GetInstanceRequest{
+		InstanceId:      common.String(job.instanceId),
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err != nil {
+		job.setStatus(err.Error())
+		return false
+	}
+	ins := resp.Instance
+	if ins.LifecycleState == core.InstanceLifecycleStateTerminating || ins.LifecycleState == core.InstanceLifecycleStateTerminated {
+		job.setStatus("实例已终止")
+		return true
+	}
+	if ins.ShapeConfig != nil && ins.ShapeConfig.Ocpus != nil && ins.ShapeConfig.MemoryInGBs != nil &&
+		*ins.ShapeConfig.Ocpus >= job.target.Ocpus && *ins.ShapeConfig.MemoryInGBs >= job.target.MemoryInGBs {
+		job.setStatus("升级成功")
+		printf("\033[1;32m[%s] 实例 %s 已升级到 %s🎉, 尝试次数: %d\033[0m\n", job.account, job.name, job.target, job.attempts)
+		sendMessage(fmt.Sprintf("[%s]", job.account), fmt.Sprintf("实例 %s 已升级到目标配置🎉\nOCPU计数: %g\n内存(GB): %g\n尝试次数: %d", job.name, job.target.Ocpus, job.target.MemoryInGBs, job.attempts))
+		return true
+	}
+	if ins.LifecycleState != core.InstanceLifecycleStateRunning && ins.LifecycleState != core.InstanceLifecycleStateStopped {
+		// 实例正在启动或正在变更配置, 稍后再试
+		job.setStatus(getInstanceState(ins.LifecycleState))
+		return false
+	}
+
+	job.mutex.Lock()
+	job.attempts++
+	job.mutex.Unlock()
+	_, err = job.client.UpdateInstance(ctx, core.UpdateInstanceRequest{
+		InstanceId: common.String(job.instanceId),
+		UpdateInstanceDetails: core.UpdateInstanceDetails{
+			ShapeConfig: &core.UpdateInstanceShapeConfigDetails{
+				Ocpus:       common.Float32(job.target.Ocpus),
+				MemoryInGBs: common.Float32(job.target.MemoryInGBs),
+			},
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err == nil {
+		// 请求已被接受, 下次检查实例配置确认是否升级成功
+		job.setStatus("正在升级")
+		return false
+	}
+	errInfo := err.Error()
+	if servErr, ok := common.IsServiceError(err); ok {
+		errInfo = servErr.GetMessage()
+	}
+	job.setStatus(errInfo)
+	policy := matchErrorPolicy(err)
+	if policy.action == errorActionAbortTemplate || policy.action == errorActionAbortAccount {
+		printf("\033[1;31m[%s] 实例 %s 自动升级失败, 错误信息: \033[0m%s\n", job.account, job.name, errInfo)
+		sendMessage(fmt.Sprintf("[%s]", job.account), fmt.Sprintf("实例 %s 自动升级失败❌\n错误信息: %s\n尝试次数: %d", job.name, errInfo, job.attempts))
+		return true
+	}
+	return false
+}
+
+// 一天中的时间段, 单位为分钟, end 小于 start 表示跨越零点
+type timeWindow struct {
+	start int
+	end   int
+}
+
+// cron 表达式: 分 时 日 月 周
+type cronSchedule struct {
+	fields [5]map[int]bool
+	// 日和周字段都有限制 (不以 * 开头) 时, 与标准 cron 相同, 满足其中一个即可
+	dayOrWeekday bool
+}
+
+// 实例模版的创建时间段, 同时满足 launchWindow 和 launchCron 时才尝试创建
+type launchSchedule struct {
+	windows []timeWindow
+	cron    *cronSchedule
+}
+
+func newLaunchSchedule(windows, cron string) (schedule *launchSchedule, err error) {
+	schedule = &launchSchedule{}
+	for _, item := range strings.Split(windows, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bounds := strings.Split(item, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("时间段格式错误: %s", item)
+		}
+		start, err1 := time.Parse("15:04", strings.TrimSpace(bounds[0]))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(bounds[1]))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("时间段格式错误: %s", item)
+		}
+		if start.Equal(end) {
+			return nil, fmt.Errorf("时间段为空: %s", item)
+		}
+		schedule.windows = append(schedule.windows, timeWindow{start: start.Hour()*60 + start.Minute(), end: end.Hour()*60 + end.Minute()})
+	}
+	if strings.TrimSpace(cron) != "" {
+		schedule.cron, err = parseCron(cron)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 先检查一天中是否有符合条件的时间, 再检查是否有符合条件的日期
+	var hasMinute bool
+	for minute := 0; minute < 24*60; minute++ {
+		if schedule.allowedMinute(minute) {
+			hasMinute = true
+			break
+		}
+	}
+	if !hasMinute || schedule.next(time.Now()).IsZero() {
+		return nil, errors.New("launchWindow 和 launchCron 没有重叠的时间, 永远不会开始创建")
+	}
+	return
+}
+
+func (s *launchSchedule) allowed(t time.Time) bool {
+	if s.cron != nil && !s.cron.matchDay(t) {
+		return false
+	}
+	return s.allowedMinute(t.Hour()*60 + t.Minute())
+}
+
+// 一天中的第 minute 分钟是否在时间段内, 并且符合 cron 表达式的分和时字段
+func (s *launchSchedule) allowedMinute(minute int) bool {
+	if s.cron != nil && (!s.cron.fields[0][minute%60] || !s.cron.fields[1][minute/60]) {
+		return false
+	}
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, w := range s.windows {
+		if w.start <= w.end && minute >= w.start && minute < w.end {
+			return true
+		}
+		if w.start > w.end && (minute >= w.start || minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回 t 之后下一个在创建时间段内的时间, 没有时返回零值
+func (s *launchSchedule) next(t time.Time) time.Time {
+	return nextMatchingMinute(t, func(day time.Time) bool {
+		return s.cron == nil || s.cron.matchDay(day)
+	}, s.allowed)
+}
+
+// 不在创建时间段内时, 等待到下一个时间段开始
+func (s *launchSchedule) wait() {
+	now := time.Now()
+	if s.allowed(now) {
+		return
+	}
+	next := s.next(now)
+	if next.IsZero() {
+		return
+	}
+	printf("\033[1;36m[%s] 不在创建时间段内, 暂停到 %s\033[0m\n", oracleSectionName, next.Format("2006-01-02 15:04"))
+	time.Sleep(time.Until(next))
+}
+
+// 解析 cron 表达式, 支持 *、数字、范围 (1-5)、列表 (1,3,5) 和步长 (*/5, 0-30/10), 周字段的 0 和 7 都表示星期日
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式应为 5 个字段 (分 时 日 月 周): %s", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	c := &cronSchedule{dayOrWeekday: !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")}
+	for i, field := range fields {
+		c.fields[i] = make(map[int]bool)
+		for _, part := range strings.Split(field, ",") {
+			step := 1
+			if idx := strings.Index(part, "/"); idx >= 0 {
+				var err error
+				step, err = strconv.Atoi(part[idx+1:])
+				if err != nil || step <= 0 {
+					return nil, fmt.Errorf("cron 表达式错误: %s", part)
+				}
+				part = part[:idx]
+			}
+			min, max := bounds[i][0], bounds[i][1]
+			if part != "*" {
+				r := strings.SplitN(part, "-", 2)
+				var err error
+				min, err = strconv.Atoi(r[0])
+				if err != nil {
+					return nil, fmt.Errorf("cron 表达式错误: %s", part)
+				}
+				if len(r) == 2 {
+					max, err = strconv.Atoi(r[1])
+					if err != nil {
+						return nil, fmt.Errorf("cron 表达式错误: %s", part)
+					}
+				} else if step == 1 {
+					max = min
+				}
+			}
+			if min < bounds[i][0] || max > bounds[i][1] || min > max {
+				return nil, fmt.Errorf("cron 表达式超出范围: %s", part)
+			}
+			for v := min; v <= max; v += step {
+				c.fields[i][v] = true
+			}
+		}
+	}
+	if c.fields[4][7] {
+		c.fields[4][0] = true
+	}
+	if c.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron 表达式永远不会匹配: %s", expr)
+	}
+	return c, nil
+}
+
+func (c *cronSchedule) match(t time.Time) bool {
+	return c.fields[0][t.Minute()] && c.fields[1][t.Hour()] && c.matchDay(t)
+}
+
+// 日期是否符合日、月和周字段
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	if !c.fields[3][int(t.Month())] {
+		return false
+	}
+	if c.dayOrWeekday {
+		return c.fields[2][t.Day()] || c.fields[4][int(t.Weekday())]
+	}
+	return c.fields[2][t.Day()] && c.fields[4][int(t.Weekday())]
+}
+
+// 返回 t 之后下一个符合 cron 表达式的时间, 没有时返回零值
+func (c *cronSchedule) next(t time.Time) time.Time {
+	return nextMatchingMinute(t, c.matchDay, c.match)
+}
+
+// 查找 t 之后第一个满足 match 的整分钟, 只在满足 dayMatch 的日期中逐分钟查找。
+// 闰年 2 月 29 日与星期的组合每 28 年重复一次, 所以最多查找 28 年, 找不到时返回零值
+func nextMatchingMinute(t time.Time, dayMatch, match func(time.Time) bool) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < 28*366; i++ {
+		if dayMatch(day) {
+			for next := day; next.Day() == day.Day(); next = next.Add(time.Minute) {
+				if next.After(t) && match(next) {
+					return next
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// 按照 cronLaunch 和 cronIP 定时批量创建实例和导出实例IP, 一直运行直到程序退出
+func runScheduler() {
+	var launchCron, ipCron *cronSchedule
+	var err error
+	if cronLaunch != "" {
+		if launchCron, err = parseCron(cronLaunch); err != nil {
+			printlnErr("解析 cronLaunch 失败", err.Error())
+			return
+		}
+	}
+	if cronIP != "" {
+		if ipCron, err = parseCron(cronIP); err != nil {
+			printlnErr("解析 cronIP 失败", err.Error())
+			return
+		}
+	}
+	if launchCron == nil && ipCron == nil {
+		printlnErr("未配置定时任务", "请在配置文件中设置 cronLaunch 或 cronIP")
+		return
+	}
+	printf("\033[1;36m开始运行定时任务, 创建实例: %s, 导出实例IP: %s\033[0m\n", cronLaunch, cronIP)
+	// 任务依次执行, 无限重试的模版会一直占用定时任务, 所以限制重试次数, 下一次定时任务时再继续尝试
+	unlimitedRetryCap = 10
+	for {
+		now := time.Now()
+		var nextLaunch, nextIP time.Time
+		if launchCron != nil {
+			nextLaunch = launchCron.next(now)
+		}
+		if ipCron != nil {
+			nextIP = ipCron.next(now)
+		}
+		next := nextLaunch
+		if next.IsZero() || (!nextIP.IsZero() && nextIP.Before(next)) {
+			next = nextIP
+		}
+		if next.IsZero() {
+			return
+		}
+		printf("下一次执行时间: %s\n", next.Format("2006-01-02 15:04"))
+		time.Sleep(time.Until(next))
+		if next.Equal(nextLaunch) {
+			multiBatchLaunchInstances()
+		}
+		if next.Equal(nextIP) {
+			multiBatchListInstancesIp()
+		}
+		// 任务执行期间错过的定时任务不会补充执行
+		logSkippedRuns("创建实例", launchCron, next)
+		logSkippedRuns("导出实例IP", ipCron, next)
+	}
+}
+
+// 显示从 since 到现在之间因任务仍在执行而跳过的定时任务
+func logSkippedRuns(name string, c *cronSchedule, since time.Time) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	var skipped int
+	var first time.Time
+	for t := c.next(since); !t.IsZero() && !t.After(now); t = c.next(t) {
+		if skipped == 0 {
+			first = t
+		}
+		skipped++
+	}
+	if skipped > 0 {
+		printf("\033[1;33m定时任务执行时间过长, 跳过了 %d 次%s任务 (第一次跳过: %s)\033[0m\n", skipped, name, first.Format("2006-01-02 15:04"))
+	}
+}
+
+// 检查抢占式实例、容量预留、专用虚拟机主机和容错域参数是否适用于实例配置
+// https://docs.oracle.com/en-us/iaas/Content/Compute/Concepts/preemptible.htm
+func validateCapacityOptions(shape string) error {
+	isVM := strings.HasPrefix(strings.ToUpper(shape), "VM.")
+	if instance.Preemptible {
+		if !isVM || isMicroShape(shape) {
+			return fmt.Errorf("%s 不支持抢占式实例", shape)
+		}
+		if instance.CapacityReservationId != "" || instance.DedicatedVmHostId != "" {
+			return errors.New("抢占式实例不能使用容量预留或专用虚拟机主机")
+		}
+	}
+	if instance.DedicatedVmHostId != "" {
+		if !isVM || isMicroShape(shape) {
+			return fmt.Errorf("%s 不支持专用虚拟机主机", shape)
+		}
+		if instance.CapacityReservationId != "" {
+			return errors.New("专用虚拟机主机不能使用容量预留")
+		}
+	}
+	if instance.CapacityReservationId != "" && isMicroShape(shape) {
+		return fmt.Errorf("%s 不支持容量预留", shape)
+	}
+	if instance.FaultDomain != "" {
+		if ok, _ := regexp.MatchString(`^(?i)FAULT-DOMAIN-[1-3]$`, instance.FaultDomain); !ok {
+			return fmt.Errorf("容错域格式错误: %s, 应为 FAULT-DOMAIN-1、FAULT-DOMAIN-2 或 FAULT-DOMAIN-3", instance.FaultDomain)
+		}
+	}
+	return nil
+}
+
+// 根据实例模版设置启动选项 (引导卷类型、网络类型、固件、传输中加密) 和平台配置 (安全启动、度量启动、TPM)
+func setLaunchOptions(request *core.LaunchInstanceRequest, shape string) error {
+	// 默认启用传输中加密
+	pvEncryption := true
+	if instance.PvEncryptionInTransit != "" {
+		var err error
+		pvEncryption, err = strconv.ParseBool(instance.PvEncryptionInTransit)
+		if err != nil {
+			return fmt.Errorf("pvEncryptionInTransit 参数错误: %s", instance.PvEncryptionInTransit)
+		}
+	}
+	request.IsPvEncryptionInTransitEnabled = common.Bool(pvEncryption)
+
+	var options core.LaunchOptions
+	var set bool
+	if instance.BootVolumeType != "" {
+		for _, v := range core.GetLaunchOptionsBootVolumeTypeEnumValues() {
+			if strings.EqualFold(string(v), instance.BootVolumeType) {
+				options.BootVolumeType = v
+			}
+		}
+		if options.BootVolumeType == "" {
+			return fmt.Errorf("bootVolumeType 参数错误: %s", instance.BootVolumeType)
+		}
+		set = true
+	}
+	if instance.NetworkType != "" {
+		for _, v := range core.GetLaunchOptionsNetworkTypeEnumValues() {
+			if strings.EqualFold(string(v), instance.NetworkType) {
+				options.NetworkType = v
+			}
+		}
+		if options.NetworkType == "" {
+			return fmt.Errorf("networkType 参数错误: %s", instance.NetworkType)
+		}
+		set = true
+	}
+	if instance.Firmware != "" {
+		switch strings.ToUpper(instance.Firmware) {
+		case "BIOS":
+			options.Firmware = core.LaunchOptionsFirmwareBios
+		case "UEFI", "UEFI_64":
+			options.Firmware = core.LaunchOptionsFirmwareUefi64
+		default:
+			return fmt.Errorf("firmware 参数错误: %s", instance.Firmware)
+		}
+		set = true
+	}
+	if set {
+		options.IsPvEncryptionInTransitEnabled = common.Bool(pvEncryption)
+		request.LaunchOptions = &options
+	}
+
+	// 屏蔽实例: https://docs.oracle.com/en-us/iaas/Content/Compute/References/shielded-instances.htm
+	secureBoot := instance.ShieldedInstance || instance.SecureBoot
+	measuredBoot := instance.ShieldedInstance || instance.MeasuredBoot
+	tpm := instance.ShieldedInstance || instance.TrustedPlatformModule || measuredBoot
+	if !secureBoot && !measuredBoot && !tpm {
+		return nil
+	}
+	if options.Firmware == core.LaunchOptionsFirmwareBios {
+		return errors.New("屏蔽实例需要使用 UEFI 固件")
+	}
+	upper := strings.ToUpper(shape)
+	switch {
+	case strings.HasPrefix(upper, "VM.STANDARD.E") && !isMicroShape(shape):
+		request.PlatformConfig = core.AmdVmLaunchInstancePlatformConfig{
+			IsSecureBootEnabled:            common.Bool(secureBoot),
+			IsTrustedPlatformModuleEnabled: common.Bool(tpm),
+			IsMeasuredBootEnabled:          common.Bool(measuredBoot),
+		}
+	case strings.HasPrefix(upper, "VM.STANDARD2.") || strings.HasPrefix(upper, "VM.STANDARD3.") || strings.HasPrefix(upper, "VM.OPTIMIZED3."):
+		request.PlatformConfig = core.IntelVmLaunchInstancePlatformConfig{
+			IsSecureBootEnabled:            common.Bool(secureBoot),
+			IsTrustedPlatformModuleEnabled: common.Bool(tpm),
+			IsMeasuredBootEnabled:          common.Bool(measuredBoot),
+		}
+	default:
+		return fmt.Errorf("%s 不支持屏蔽实例", shape)
+	}
+	return nil
+}
+
+// 修改实例引导卷的性能 (VPU/GB), 10: 均衡, 20: 性能较高
+func setInstanceBootVolumeVpus(ins core.Instance, vpusPerGB int64) error {
+	attachments, err := computeClient.ListBootVolumeAttachments(ctx, core.ListBootVolumeAttachmentsRequest{
+		AvailabilityDomain: ins.AvailabilityDomain,
+		CompartmentId:      ins.CompartmentId,
+		InstanceId:         ins.Id,
+		RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments.Items {
+		_, err = updateBootVolume(attachment.BootVolumeId, nil, common.Int64(vpusPerGB))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDryRun() bool {
+	return dryRun || instance.DryRun
+}
+
+// 输出 dry run 结果: 创建实例请求、可用性域和将要创建的网络资源
+func printDryRun(request core.LaunchInstanceRequest, ads []identity.AvailabilityDomain, adNotFixed bool, sum int32) {
+	fmt.Printf("\n\033[1;32m[%s] Dry Run, 不会创建任何资源\033[0m\n", oracleSectionName)
+	var adNames []string
+	if adNotFixed {
+		for _, ad := range ads {
+			adNames = append(adNames, *ad.Name)
+		}
+	} else {
+		adNames = append(adNames, *request.AvailabilityDomain)
+	}
+	fmt.Printf("创建个数: %d\n", sum)
+	fmt.Printf("可用性域: %s\n", strings.Join(adNames, ", "))
+	if len(networkPlan) == 0 {
+		fmt.Println("网络资源: 使用已有的网络资源")
+	} else {
+		fmt.Println("网络资源:")
+		for _, item := range networkPlan {
+			fmt.Printf("  - %s\n", item)
+		}
+	}
+	data, err := json.MarshalIndent(request.LaunchInstanceDetails, "", "  ")
+	if err != nil {
+		printlnErr("序列化创建实例请求失败", err.Error())
+		return
+	}
+	fmt.Printf("创建实例请求:\n%s\n\n", string(data))
+}
+
+// 获取实例模版的 Shape, 弹性实例设置了 OCPU 和内存时不需要查询
+func getTemplateShape(image core.Image) (shape core.Shape, err error) {
+	if strings.Contains(strings.ToLower(instance.Shape), "flex") && instance.Ocpus > 0 && instance.MemoryInGBs > 0 {
+		shape.Shape = common.String(instance.Shape)
+		shape.Ocpus = common.Float32(instance.Ocpus)
+		shape.MemoryInGBs = common.Float32(instance.MemoryInGBs)
+		return
+	}
+	fmt.Println("正在获取Shape信息...")
+	return getShape(image.Id, instance.Shape)
+}
+
+// 按实例模版生成创建实例请求 (不包括实例名称和可用性域), 创建实例和创建实例配置时使用相同的参数
+// bootVolume 不为 nil 时使用已有的引导卷创建实例
+func buildLaunchInstanceRequest(image core.Image, shape core.Shape, bootVolume *core.BootVolume) (request core.LaunchInstanceRequest, subnet core.Subnet, err error) {
+	request.CompartmentId = common.String(oracle.Tenancy)
+	request.Shape = shape.Shape
+	if strings.Contains(strings.ToLower(*shape.Shape), "flex") {
+		request.ShapeConfig = &core.LaunchInstanceShapeConfigDetails{
+			Ocpus:       shape.Ocpus,
+			MemoryInGBs: shape.MemoryInGBs,
+		}
+		if instance.Burstable == "1/8" {
+			request.ShapeConfig.BaselineOcpuUtilization = core.LaunchInstanceShapeConfigDetailsBaselineOcpuUtilization8
+		} else if instance.Burstable == "1/2" {
+			request.ShapeConfig.BaselineOcpuUtilization = core.LaunchInstanceShapeConfigDetailsBaselineOcpuUtilization2
+		}
+	}
+
+	// 抢占式实例、容量预留、专用虚拟机主机和容错域
+	err = validateCapacityOptions(*shape.Shape)
+	if err != nil {
+		err = fmt.Errorf("实例模版参数错误: %s", err.Error())
+		return
+	}
+	if instance.Preemptible {
+		request.PreemptibleInstanceConfig = &core.PreemptibleInstanceConfigDetails{
+			PreemptionAction: core.TerminatePreemptionAction{
+				PreserveBootVolume: common.Bool(instance.PreserveBootVolume),
+			},
+		}
+	}
+	if instance.CapacityReservationId != "" {
+		request.CapacityReservationId = common.String(instance.CapacityReservationId)
+	}
+	if instance.DedicatedVmHostId != "" {
+		request.DedicatedVmHostId = common.String(instance.DedicatedVmHostId)
+	}
+	if instance.FaultDomain != "" {
+		request.FaultDomain = common.String(strings.ToUpper(instance.FaultDomain))
+	}
+
+	// 启动选项和平台配置
+	err = setLaunchOptions(&request, *shape.Shape)
+	if err != nil {
+		err = fmt.Errorf("实例模版参数错误: %s", err.Error())
+		return
+	}
+
+	// create a subnet or get the one already created
+	fmt.Println("正在获取子网...")
+	networkPlan = nil
+	subnet, err = CreateOrGetNetworkInfrastructure(ctx, networkClient)
+	if err != nil {
+		err = fmt.Errorf("获取子网失败: %s", err.Error())
+		return
+	}
+	fmt.Println("子网:", *subnet.DisplayName)
+	var nsgIds []string
+	nsgIds, err = getLaunchNsgIds(ctx, networkClient, subnet)
+	if err != nil {
+		err = fmt.Errorf("获取网络安全组失败: %s", err.Error())
+		return
+	}
+	request.CreateVnicDetails = &core.CreateVnicDetails{SubnetId: subnet.Id, NsgIds: nsgIds}
+
+	if bootVolume != nil {
+		request.SourceDetails = core.InstanceSourceViaBootVolumeDetails{BootVolumeId: bootVolume.Id}
+	} else {
+		sd := core.InstanceSourceViaImageDetails{}
+		sd.ImageId = image.Id
+		if instance.BootVolumeSizeInGBs > 0 {
+			sd.BootVolumeSizeInGBs = common.Int64(instance.BootVolumeSizeInGBs)
+		}
+		request.SourceDetails = sd
+	}
+
+	metaData := map[string]string{}
+	metaData["ssh_authorized_keys"] = instance.SSH_Public_Key
+	if instance.CloudInit != "" {
+		metaData["user_data"] = instance.CloudInit
+	}
+	request.Metadata = metaData
+	if instanceSectionName != "" {
+		request.FreeformTags = map[string]string{templateTagKey: instanceSectionName}
+	}
+	return
+}
+
+// 将实例模版转换为实例配置的启动参数, 与创建实例时使用相同的请求
+func buildInstanceConfigurationLaunchDetails() (details core.InstanceConfigurationLaunchInstanceDetails, subnet core.Subnet, shape core.Shape, storageInGBs int64, err error) {
+	fmt.Println("正在获取系统镜像...")
+	image, err := GetImage(ctx, computeClient)
+	if err != nil {
+		return
+	}
+	fmt.Println("系统镜像:", *image.DisplayName, *image.Id)
+
+	shape, err = getTemplateShape(image)
+	if err != nil {
+		return
+	}
+	var request core.LaunchInstanceRequest
+	request, subnet, err = buildLaunchInstanceRequest(image, shape, nil)
+	if err != nil {
+		return
+	}
+	if instance.InstanceDisplayName != "" {
+		request.DisplayName = common.String(instance.InstanceDisplayName)
+	}
+	if instance.BootVolumeSizeInGBs > 0 {
+		storageInGBs = instance.BootVolumeSizeInGBs
+	} else {
+		storageInGBs = int64(math.Round(float64(*image.SizeInMBs) / float64(1024)))
+	}
+
+	// 实例配置的启动参数与创建实例的参数字段相同 (包括镜像来源和平台配置的类型), 通过 JSON 转换
+	data, err := json.Marshal(request.LaunchInstanceDetails)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &details)
+	return
+}
+
+// 按照实例模版中的 desiredNames 持续检查账号中的实例, 创建缺少的实例并报告偏差, 一直运行直到程序退出
+func runReconciler(accounts []*ini.Section) {
+	interval := reconcileInterval
+	if interval <= 0 {
+		interval = 300
+	}
+	printf("\033[1;36m开始运行实例编排, 检查间隔: %d 秒\033[0m\n", interval)
+	for {
+		for _, sec := range accounts {
+			err := initVar(sec)
+			if err != nil {
+				continue
+			}
+			availabilityDomains, err = ListAvailabilityDomains()
+			if err != nil {
+				printlnErr("获取可用性域失败", err.Error())
+				continue
+			}
+			reconcileAccount(sec)
+		}
+		printf("下一次检查时间: %s\n", time.Now().Add(time.Duration(interval)*time.Second).Format("2006-01-02 15:04:05"))
+		sleepSecond(interval)
+	}
+}
+
+// 对比账号中的实例模版和实例, 创建缺少的实例, 启动已停止的实例, 报告配置不一致和多余的实例
+func reconcileAccount(oracleSec *ini.Section) {
+	var instanceSections []*ini.Section
+	for _, sec := range append(instanceBaseSection.ChildSections(), oracleSec.ChildSections()...) {
+		if sec.Key("desiredNames").Value() != "" {
+			instanceSections = append(instanceSections, sec)
+		}
+	}
+	if len(instanceSections) == 0 {
+		return
+	}
+
+	var instances []core.Instance
+	var nextPage *string
+	for {
+		ins, page, err := ListInstances(ctx, computeClient, nextPage)
+		if err != nil {
+			printlnErr("获取实例失败", err.Error())
+			return
+		}
+		for _, i := range ins {
+			if i.LifecycleState != core.InstanceLifecycleStateTerminating && i.LifecycleState != core.InstanceLifecycleStateTerminated {
+				instances = append(instances, i)
+			}
+		}
+		nextPage = page
+		if nextPage == nil || len(ins) == 0 {
+			break
+		}
+	}
+
+	var drift []string
+	for _, instanceSec := range instanceSections {
+		instanceSectionName = instanceSec.Name()
+		instance = Instance{}
+		err := instanceSec.MapTo(&instance)
+		if err != nil {
+			printlnErr("解析实例模版参数失败", err.Error())
+			continue
+		}
+		template := instance
+
+		desired := make(map[string]bool)
+		var missing []string
+		for _, name := range strings.Split(template.DesiredNames, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || desired[name] {
+				continue
+			}
+			desired[name] = true
+			// 只匹配使用该模版创建的实例, 其他模版或手动创建的同名实例不算存在
+			var found *core.Instance
+			for i := range instances {
+				if *instances[i].DisplayName == name && instances[i].FreeformTags[templateTagKey] == instanceSectionName {
+					found = &instances[i]
+					break
+				}
+			}
+			if found == nil {
+				missing = append(missing, name)
+				continue
+			}
+			drift = append(drift, instanceDrift(*found, template)...)
+			if found.LifecycleState == core.InstanceLifecycleStateStopped {
+				if template.ReconcileStart {
+					_, err := instanceAction(found.Id, core.InstanceActionActionStart)
+					if err != nil {
+						drift = append(drift, fmt.Sprintf("%s: 已停止, 启动失败 %s", name, err.Error()))
+					} else {
+						drift = append(drift, fmt.Sprintf("%s: 已停止, 正在启动", name))
+					}
+				} else {
+					drift = append(drift, fmt.Sprintf("%s: 已停止", name))
+				}
+			}
+		}
+		// 使用该模版创建但不在 desiredNames 中的实例
+		for _, i := range instances {
+			if i.FreeformTags[templateTagKey] == instanceSectionName && !desired[*i.DisplayName] {
+				drift = append(drift, fmt.Sprintf("%s: 多余的实例 (模版 %s)", *i.DisplayName, instanceSectionName))
+			}
+		}
+
+		for _, name := range missing {
+			printf("\033[1;36m[%s] 缺少实例 %s, 开始创建\033[0m\n", oracleSectionName, name)
+			instance = template
+			instance.InstanceDisplayName = name
+			instance.Sum = 1
+			instance.Each = 0
+			// 每次检查只尝试有限的次数, 避免 retry=-1 的模版一直占用循环, 未创建成功的实例在下一次检查时重试
+			instance.Retry = 1
+			_, num, abort := LaunchInstances(availabilityDomains)
+			if num == 0 {
+				drift = append(drift, fmt.Sprintf("%s: 缺少实例, 创建失败", name))
+			}
+			if abort {
+				break
+			}
+		}
+	}
+
+	sort.Strings(drift)
+	text := strings.Join(drift, "\n")
+	changed := reconcileDrift[oracleSectionName] != text
+	reconcileDrift[oracleSectionName] = text
+	if len(drift) > 0 {
+		text = "实例状态与模版不一致:\n" + text
+		printf("\033[1;33m[%s] %s\033[0m\n", oracleSectionName, text)
+		if changed {
+			sendMessage(fmt.Sprintf("[%s]", oracleSectionName), text)
+		}
+	} else {
+		printf("\033[1;32m[%s] 实例状态与模版一致\033[0m\n", oracleSectionName)
+		if changed {
+			sendMessage(fmt.Sprintf("[%s]", oracleSectionName), "实例状态已与模版一致")
+		}
+	}
+}
+
+// 对比实例和模版的 Shape、CPU 和内存
+func instanceDrift(ins core.Instance, template Instance) (drift []string) {
+	name := *ins.DisplayName
+	if template.Shape != "" && !strings.EqualFold(*ins.Shape, template.Shape) {
+		drift = append(drift, fmt.Sprintf("%s: Shape %s, 模版 %s", name, *ins.Shape, template.Shape))
+		return
+	}
+	if ins.ShapeConfig == nil || template.ShapeLadder != "" {
+		return
+	}
+	if template.Ocpus > 0 && ins.ShapeConfig.Ocpus != nil && *ins.ShapeConfig.Ocpus != template.Ocpus {
+		drift = append(drift, fmt.Sprintf("%s: CPU个数 %g, 模版 %g", name, *ins.ShapeConfig.Ocpus, template.Ocpus))
+	}
+	if template.MemoryInGBs > 0 && ins.ShapeConfig.MemoryInGBs != nil && *ins.ShapeConfig.MemoryInGBs != template.MemoryInGBs {
+		drift = append(drift, fmt.Sprintf("%s: 内存 %gGB, 模版 %gGB", name, *ins.ShapeConfig.MemoryInGBs, template.MemoryInGBs))
+	}
+	return
+}
+
+// 解析就绪检查, 多个检查用英文逗号分隔, 按顺序执行。例如 running,tcp:22,ssh,http://{ip}/health,cloud-init
+func parseReadinessProbes(str string) (probes []readinessProbe, err error) {
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		lower := strings.ToLower(item)
+		switch {
+		case lower == "running" || lower == "cloud-init":
+			probes = append(probes, readinessProbe{Kind: lower})
+		case lower == "ssh":
+			probes = append(probes, readinessProbe{Kind: "ssh", Target: "22"})
+		case strings.HasPrefix(lower, "tcp:") || strings.HasPrefix(lower, "ssh:"):
+			port, err := strconv.Atoi(item[4:])
+			if err != nil || port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("端口错误: %s", item)
+			}
+			probes = append(probes, readinessProbe{Kind: lower[:3], Target: item[4:]})
+		case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+			probes = append(probes, readinessProbe{Kind: "http", Target: item})
+		default:
+			return nil, fmt.Errorf("不支持的就绪检查: %s", item)
+		}
+	}
+	return
+}
+
+// 在后台执行就绪检查, 完成后显示结果, 检查通过时执行 on_success 钩子, 否则执行 on_failure 钩子
+// notify 为 true 时把检查结果加到创建成功的消息 text 中, messageId 不为 0 时编辑该消息, 否则发送新消息
+func reportReadiness(client core.ComputeClient, template Instance, event hookEvent, ins core.Instance, ips []string, probes []readinessProbe, timeout time.Duration, notify bool, messageId int, text string) {
+	results, ready := runReadinessProbes(client, ins, ips, probes, timeout)
+	status := "就绪检查通过✅"
+	if !ready {
+		status = "就绪检查未通过⚠️"
+	}
+	readiness := "就绪检查: " + strings.Join(results, ", ")
+	printf("\033[1;32m[%s] 实例 %s %s\033[0m\n%s\n", event.Account, event.Name, status, readiness)
+	if notify {
+		text += "\n" + status + "\n" + readiness
+		var err error
+		if messageId != 0 {
+			_, err = editMessage(messageId, "", text)
+		} else {
+			_, err = sendMessage("", text)
+		}
+		if err != nil {
+			printlnErr("Telegram 消息提醒发送失败", err.Error())
+		}
+	}
+	event.Message = event.Message + ", " + status + "\n" + readiness
+	if ready {
+		runHook(template, hookOnSuccess, event)
+	} else {
+		runHook(template, hookOnFailure, event)
+	}
+}
+
+// 按顺序执行就绪检查, 每个检查失败后每 5 秒重试一次, 所有检查共用超时时间
+func runReadinessProbes(client core.ComputeClient, ins core.Instance, ips []string, probes []readinessProbe, timeout time.Duration) (results []string, ready bool) {
+	deadline := time.Now().Add(timeout)
+	var ip string
+	if len(ips) > 0 {
+		ip = ips[0]
+	}
+	ready = true
+	for _, probe := range probes {
+		start := time.Now()
+		var err error
+		for {
+			err = probe.check(client, ins, ip)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(5 * time.Second)
+		}
+		if err != nil {
+			ready = false
+			results = append(results, fmt.Sprintf("%s ❌ (%s)", probe, err.Error()))
+		} else {
+			results = append(results, fmt.Sprintf("%s ✅ (%s)", probe, fmtDuration(time.Since(start))))
+		}
+	}
+	return
+}
+
+func (p readinessProbe) String() string {
+	switch p.Kind {
+	case "tcp", "ssh":
+		return p.Kind + ":" + p.Target
+	case "http":
+		return p.Target
+	}
+	return p.Kind
+}
+
+// cloud-init 完成时控制台输出的信息
+var cloudInitFinishedRegexp = regexp.MustCompile(`Cloud-init v\. \S+ finished`)
+
+// 执行一次就绪检查
+func (p readinessProbe) check(client core.ComputeClient, ins core.Instance, ip string) error {
+	if p.Kind != "running" && p.Kind != "cloud-init" && ip == "" {
+		return errors.New("没有公共IP")
+	}
+	switch p.Kind {
+	case "running":
+		resp, err := client.GetInstance(ctx, core.GetInstanceRequest{
+			InstanceId:      ins.Id,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			return err
+		}
+		if resp.LifecycleState != core.InstanceLifecycleStateRunning {
+			return errors.New(getInstanceState(resp.LifecycleState))
+		}
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, p.Target), 5*time.Second)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	case "ssh":
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, p.Target), 5*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		banner := make([]byte, 255)
+		n, err := conn.Read(banner)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(string(banner[:n]), "SSH-") {
+			return errors.New("不是 SSH 服务")
+		}
+	case "http":
+		client := http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(strings.ReplaceAll(p.Target, "{ip}", ip))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return errors.New(resp.Status)
+		}
+	case "cloud-init":
+		content, err := getConsoleHistory(client, ins.Id)
+		if err != nil {
+			return err
+		}
+		if !cloudInitFinishedRegexp.MatchString(content) {
+			return errors.New("cloud-init 未完成")
+		}
+	}
+	return nil
+}
+
+// 创建实例时记录了实例模版名称的实例, 使用实例模版中的钩子, 公共IP变化时同时更新 DNS 记录
+func runInstanceHook(ins core.Instance, name string, ips []string) {
+	templateName := ins.FreeformTags[templateTagKey]
+	if templateName == "" {
+		return
+	}
+	for _, sec := range append(instanceBaseSection.ChildSections(), oracleSection.ChildSections()...) {
+		if sec.Name() != templateName {
+			continue
+		}
+		var template Instance
+		if err := sec.MapTo(&template); err != nil {
+			printlnErr("解析实例模版参数失败", err.Error())
+			return
+		}
+		if name == hookOnIpChange {
+			updateDnsRecords(template, ins, ips)
+		}
+		event := newHookEvent(ins, ips, "")
+		event.Template = templateName
+		runHook(template, name, event)
+		return
+	}
+}
+
+func newHookEvent(ins core.Instance, ips []string, message string) hookEvent {
+	event := hookEvent{
+		Account:  oracleSectionName,
+		Region:   oracle.Region,
+		Template: instanceSectionName,
+		IPs:      ips,
+		Message:  message,
+	}
+	if ins.Id != nil {
+		event.InstanceId = *ins.Id
+	}
+	if ins.DisplayName != nil {
+		event.Name = *ins.DisplayName
+	}
+	if ins.AvailabilityDomain != nil {
+		event.AvailabilityDomain = *ins.AvailabilityDomain
+	}
+	if ins.Shape != nil {
+		event.Shape = *ins.Shape
+	}
+	return event
+}
+
+// 执行实例模版中的钩子。以 http:// 或 https:// 开头时以 POST 方式发送 JSON, 否则执行命令,
+// 命令从标准输入读取 JSON, 或者从 OCI_HELP_* 环境变量读取事件信息
+func runHook(template Instance, name string, event hookEvent) {
+	var hook string
+	switch name {
+	case hookOnSuccess:
+		hook = template.OnSuccess
+	case hookOnFailure:
+		hook = template.OnFailure
+	case hookOnIpChange:
+		hook = template.OnIpChange
+	case hookOnTerminate:
+		hook = template.OnTerminate
+	}
+	if hook == "" {
+		return
+	}
+	event.Event = name
+	timeout := time.Duration(template.HookTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		printlnErr("序列化钩子事件失败", err.Error())
+		return
+	}
+
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var out []byte
+	if strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://") {
+		fmt.Printf("执行钩子 %s: POST %s\n", name, hook)
+		var req *http.Request
+		req, err = http.NewRequestWithContext(hookCtx, http.MethodPost, hook, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			var resp *http.Response
+			resp, err = http.DefaultClient.Do(req)
+			if err == nil {
+				out, _ = ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+				resp.Body.Close()
+				if resp.StatusCode >= 400 {
+					err = errors.New(resp.Status)
+				}
+			}
+		}
+	} else {
+		fmt.Printf("执行钩子 %s: %s\n", name, hook)
+		shell, shellArg := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, shellArg = "cmd", "/C"
+		}
+		c := exec.CommandContext(hookCtx, shell, shellArg, hook)
+		c.Stdin = bytes.NewReader(data)
+		c.Env = append(os.Environ(),
+			"OCI_HELP_EVENT="+event.Event,
+			"OCI_HELP_ACCOUNT="+event.Account,
+			"OCI_HELP_REGION="+event.Region,
+			"OCI_HELP_TEMPLATE="+event.Template,
+			"OCI_HELP_INSTANCE_ID="+event.InstanceId,
+			"OCI_HELP_INSTANCE_NAME="+event.Name,
+			"OCI_HELP_IPS="+strings.Join(event.IPs, ","),
+			"OCI_HELP_AVAILABILITY_DOMAIN="+event.AvailabilityDomain,
+			"OCI_HELP_SHAPE="+event.Shape,
+			"OCI_HELP_MESSAGE="+event.Message,
+		)
+		out, err = c.CombinedOutput()
+	}
+	if hookCtx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("超时 (%s)", timeout)
+	}
+	if len(out) > 0 {
+		fmt.Println(strings.TrimSpace(string(out)))
+	}
+	if err != nil {
+		printlnErr("执行钩子 "+name+" 失败", err.Error())
+	}
+}
+
+// 定期检查实例的公共IP, 连续失败达到次数后更换公共IP, 一直运行直到程序退出
+func runWatchdog(accounts []*ini.Section) {
+	probes, err := parseReadinessProbes(watchdog.Probes)
+	if err != nil {
+		printlnErr("解析 WATCHDOG probes 失败", err.Error())
+		return
+	}
+	for _, probe := range probes {
+		if probe.Kind != "tcp" && probe.Kind != "ssh" && probe.Kind != "http" {
+			printlnErr("WATCHDOG probes 只支持 tcp、ssh 和检测接口 URL", probe.String())
+			return
+		}
+	}
+	if len(probes) == 0 {
+		printlnErr("未配置公共IP检查方式", "请在配置文件的 WATCHDOG 中设置 probes")
+		return
+	}
+	interval := watchdog.Interval
+	if interval <= 0 {
+		interval = 300
+	}
+	failures := watchdog.Failures
+	if failures <= 0 {
+		failures = 3
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Split(watchdog.Instances, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+
+	// 一次检查中实例的检查结果
+	type checkResult struct {
+		account *ini.Section
+		ins     core.Instance
+		vnics   []core.Vnic
+		ip      string
+		errs    []string
+	}
+	states := make(map[string]*watchdogState)
+	printf("\033[1;36m开始检查实例公共IP, 检查方式: %s, 检查间隔: %d 秒\033[0m\n", watchdog.Probes, interval)
+	for {
+		var results []checkResult
+		var failed int
+		var current *ini.Section
+		for _, sec := range accounts {
+			if initVar(sec) != nil {
+				continue
+			}
+			current = sec
+			var instances []core.Instance
+			var nextPage *string
+			for {
+				ins, page, err := ListInstances(ctx, computeClient, nextPage)
+				if err != nil {
+					printlnErr("获取实例失败", err.Error())
+					break
+				}
+				for _, i := range ins {
+					if i.LifecycleState == core.InstanceLifecycleStateRunning && (len(names) == 0 || names[*i.DisplayName]) {
+						instances = append(instances, i)
+					}
+				}
+				nextPage = page
+				if nextPage == nil || len(ins) == 0 {
+					break
+				}
+			}
+			for _, ins := range instances {
+				vnics, ip, errs, err := checkPublicIp(ins, probes)
+				if err != nil {
+					printlnErr("获取实例VNIC失败", err.Error())
+					continue
+				}
+				if ip == "" {
+					continue
+				}
+				results = append(results, checkResult{account: sec, ins: ins, vnics: vnics, ip: ip, errs: errs})
+				if len(errs) > 0 {
+					failed++
+				}
+			}
+		}
+
+		if len(results) > 1 && failed == len(results) {
+			// 所有实例同时检查失败, 更可能是本机网络故障, 本次检查不计入失败次数
+			printf("\033[1;33m所有实例 (%d 个) 的公共IP检查都失败, 可能是本机网络故障, 本次不更换公共IP\033[0m\n", failed)
+		} else {
+			for _, r := range results {
+				state := states[*r.ins.Id]
+				if state == nil {
+					state = &watchdogState{}
+					states[*r.ins.Id] = state
+				}
+				if len(r.errs) == 0 {
+					state.failures = 0
+					continue
+				}
+				// 更换公共IP时使用实例所在账号的 client
+				if r.account != current {
+					if initVar(r.account) != nil {
+						continue
+					}
+					current = r.account
+				}
+				watchInstance(r.ins, r.vnics, r.ip, r.errs, failures, state)
+			}
+		}
+		sleepSecond(interval)
+	}
+}
+
+// 检查实例主网卡的公共IP, 返回检查失败的信息。没有公共IP时 ip 为空
+func checkPublicIp(ins core.Instance, probes []readinessProbe) (vnics []core.Vnic, ip string, errs []string, err error) {
+	vnics, err = getInstanceVnics(ins.Id)
+	if err != nil {
+		return
+	}
+	for _, vnic := range vnics {
+		if vnic.IsPrimary != nil && *vnic.IsPrimary && vnic.PublicIp != nil {
+			ip = *vnic.PublicIp
+		}
+	}
+	if ip == "" {
+		return
+	}
+	for _, probe := range probes {
+		if err := probe.check(computeClient, ins, ip); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", probe, err.Error()))
+		}
+	}
+	return
+}
+
+// 记录实例公共IP的检查失败, 任一检查失败即记为一次失败, 连续失败达到次数后更换公共IP
+func watchInstance(ins core.Instance, vnics []core.Vnic, ip string, errs []string, failures int32, state *watchdogState) {
+	state.failures++
+	printf("\033[1;33m[%s] 实例 %s 公共IP %s 检查失败 (%d/%d): %s\033[0m\n", oracleSectionName, *ins.DisplayName, ip, state.failures, failures, strings.Join(errs, ", "))
+	if state.failures < failures {
+		return
+	}
+
+	// 预留公共IP不会自动更换, 更换会取消分配预留公共IP并创建新的临时公共IP
+	privateIp, err := getPrimaryPrivateIp(vnics)
+	if err != nil {
+		printlnErr("获取私有IP失败", err.Error())
+		return
+	}
+	current, err := getPublicIp(privateIp.Id)
+	if err != nil {
+		printlnErr("获取公共IP失败", err.Error())
+		return
+	}
+	if current.Lifetime == core.PublicIpLifetimeReserved {
+		printf("\033[1;33m[%s] 实例 %s 使用预留公共IP %s, 不自动更换\033[0m\n", oracleSectionName, *ins.DisplayName, ip)
+		if state.failures == failures {
+			sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("实例 %s 预留公共IP %s 无法连接, 预留公共IP不会自动更换\n检查结果: %s", *ins.DisplayName, ip, strings.Join(errs, ", ")))
+		}
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if state.day != today {
+		state.day, state.rotations = today, 0
+	}
+	if watchdog.Cooldown > 0 && time.Since(state.rotatedAt) < time.Duration(watchdog.Cooldown)*time.Second {
+		printf("\033[1;33m[%s] 实例 %s 距离上次更换公共IP不足 %d 秒, 暂不更换\033[0m\n", oracleSectionName, *ins.DisplayName, watchdog.Cooldown)
+		return
+	}
+	if watchdog.MaxPerDay > 0 && state.rotations >= watchdog.MaxPerDay {
+		if state.failures == failures {
+			sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("实例 %s 公共IP %s 无法连接, 今天已更换 %d 次, 不再更换", *ins.DisplayName, ip, state.rotations))
+		}
+		return
+	}
+
+	publicIp, err := changePublicIp(vnics, nil)
+	state.rotatedAt = time.Now()
+	if err != nil {
+		printlnErr("更换实例公共IP失败", err.Error())
+		sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("实例 %s 公共IP %s 无法连接, 更换公共IP失败: %s", *ins.DisplayName, ip, err.Error()))
+		return
+	}
+	state.failures = 0
+	state.rotations++
+	printf("\033[1;32m[%s] 实例 %s 公共IP已更换: %s -> %s\033[0m\n", oracleSectionName, *ins.DisplayName, ip, *publicIp.IpAddress)
+	sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("实例 %s 公共IP无法连接, 已自动更换\n原公共IP: %s\n新公共IP: %s\n检查结果: %s\n今天已更换: %d 次", *ins.DisplayName, ip, *publicIp.IpAddress, strings.Join(errs, ", "), state.rotations))
+	runInstanceHook(ins, hookOnIpChange, []string{*publicIp.IpAddress})
+}
+
+// 实例名称中不能用于域名的字符
+var dnsHostnameInvalidRegexp = regexp.MustCompile(`[^A-Za-z0-9-]`)
+
+// 按照实例模版中的 DNS 配置更新实例的 A 记录 (公共IP) 和 AAAA 记录 (IPv6 地址)
+func updateDnsRecords(template Instance, ins core.Instance, ips []string) {
+	if template.DnsProvider == "" {
+		return
+	}
+	hostname := strings.ToLower(dnsHostnameInvalidRegexp.ReplaceAllString(*ins.DisplayName, "-"))
+	name := strings.TrimSuffix(strings.ReplaceAll(template.DnsName, "{name}", hostname), ".")
+	if name == "" {
+		printlnErr("更新 DNS 记录失败", "未设置 dnsName")
+		return
+	}
+	zone := strings.TrimSuffix(template.DnsZone, ".")
+	if zone == "" {
+		// 无法可靠地从域名推断区域 (例如 host.example.co.uk), 仅当域名只有两级时使用域名本身作为区域
+		labels := strings.Split(name, ".")
+		if len(labels) != 2 {
+			printlnErr("更新 DNS 记录失败", "无法确定 "+name+" 所在的区域, 请设置 dnsZone")
+			return
+		}
+		zone = name
+	}
+	ttl := template.DnsTtl
+	if ttl <= 0 {
+		ttl = 300
+	}
+
+	records := map[string][]string{}
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			records["A"] = append(records["A"], ip)
+		}
+	}
+	var ipv6Failed bool // 获取 IPv6 地址失败时不修改 AAAA 记录
+	if template.DnsIpv6 {
+		vnics, err := getInstanceVnics(ins.Id)
+		if err != nil {
+			printlnErr("获取实例VNIC失败", err.Error())
+			ipv6Failed = true
+		}
+		for _, vnic := range vnics {
+			ipv6s, err := getIpv6s(vnic.Id)
+			if err != nil {
+				printlnErr("获取IPv6地址失败", err.Error())
+				ipv6Failed = true
+			}
+			for _, ipv6 := range ipv6s {
+				records["AAAA"] = append(records["AAAA"], *ipv6.IpAddress)
+			}
+		}
+	}
+
+	for _, rtype := range []string{"A", "AAAA"} {
+		values := records[rtype]
+		if rtype == "AAAA" && (!template.DnsIpv6 || ipv6Failed) {
+			continue
+		}
+		// 没有对应类型的地址时删除原有记录, 避免域名继续指向已经失效的地址
+		var err error
+		switch strings.ToLower(template.DnsProvider) {
+		case "cloudflare":
+			err = cloudflareUpdate(template.CloudflareToken, zone, name, rtype, ttl, values)
+		case "oci":
+			err = ociDnsUpdate(zone, name, rtype, ttl, values)
+		case "rfc2136":
+			err = rfc2136Update(template.DnsServer, zone, name, rtype, ttl, values, template.TsigKey, template.TsigAlgorithm)
+		default:
+			err = fmt.Errorf("不支持的 dnsProvider: %s", template.DnsProvider)
+		}
+		if err != nil {
+			printlnErr(fmt.Sprintf("更新 DNS 记录 %s %s 失败", name, rtype), err.Error())
+			sendMessage(fmt.Sprintf("[%s]", oracleSectionName), fmt.Sprintf("更新 DNS 记录失败\n域名: %s\n类型: %s\n错误信息: %s", name, rtype, err.Error()))
+		} else if len(values) == 0 {
+			printf("\033[1;32m[%s] 已删除 DNS 记录 %s %s\033[0m\n", oracleSectionName, name, rtype)
+		} else {
+			printf("\033[1;32m[%s] 已更新 DNS 记录 %s %s: %s\033[0m\n", oracleSectionName, name, rtype, strings.Join(values, ", "))
+		}
+	}
+}
+
+// 使用 Cloudflare API 更新记录, 多余的同类型记录会被删除, values 为空时删除所有同类型记录
+func cloudflareUpdate(token, zone, name, rtype string, ttl int, values []string) error {
+	if token == "" {
+		return errors.New("未设置 cloudflareToken")
+	}
+	var zones []struct {
+		Id string `json:"id"`
+	}
+	err := cloudflareRequest(token, http.MethodGet, "/zones?name="+url.QueryEscape(zone), nil, &zones)
+	if err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("未找到区域 %s", zone)
+	}
+	path := "/zones/" + zones[0].Id + "/dns_records"
+	var existing []struct {
+		Id string `json:"id"`
+	}
+	err = cloudflareRequest(token, http.MethodGet, path+"?type="+rtype+"&name="+url.QueryEscape(name), nil, &existing)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		record := map[string]interface{}{"type": rtype, "name": name, "content": value, "ttl": ttl, "proxied": false}
+		if i < len(existing) {
+			err = cloudflareRequest(token, http.MethodPut, path+"/"+existing[i].Id, record, nil)
+		} else {
+			err = cloudflareRequest(token, http.MethodPost, path, record, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := len(values); i < len(existing); i++ {
+		err = cloudflareRequest(token, http.MethodDelete, path+"/"+existing[i].Id, nil, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cloudflareRequest(token, method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "https://api.cloudflare.com/client/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	client := common.BaseClient{HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+	setProxyOrNot(&client)
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var cfResp struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&cfResp)
+	if err != nil {
+		return fmt.Errorf("%s: %s", resp.Status, err.Error())
+	}
+	if !cfResp.Success {
+		var messages []string
+		for _, e := range cfResp.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, strings.Join(messages, ", "))
+	}
+	if result != nil {
+		return json.Unmarshal(cfResp.Result, result)
+	}
+	return nil
+}
+
+// 使用 OCI DNS 更新记录, 替换同名同类型的所有记录, values 为空时删除记录
+func ociDnsUpdate(zone, name, rtype string, ttl int, values []string) error {
+	if len(values) == 0 {
+		_, err := dnsClient.DeleteRRSet(ctx, dns.DeleteRRSetRequest{
+			ZoneNameOrId:    common.String(zone),
+			Domain:          common.String(name),
+			Rtype:           common.String(rtype),
+			CompartmentId:   common.String(oracle.Tenancy),
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if servErr, ok := common.IsServiceError(err); ok && servErr.GetHTTPStatusCode() == 404 {
+			// 记录不存在
+			return nil
+		}
+		return err
+	}
+	var items []dns.RecordDetails
+	for _, value := range values {
+		items = append(items, dns.RecordDetails{
+			Domain: common.String(name),
+			Rdata:  common.String(value),
+			Rtype:  common.String(rtype),
+			Ttl:    common.Int(ttl),
+		})
+	}
+	_, err := dnsClient.UpdateRRSet(ctx, dns.UpdateRRSetRequest{
+		ZoneNameOrId:       common.String(zone),
+		Domain:             common.String(name),
+		Rtype:              common.String(rtype),
+		CompartmentId:      common.String(oracle.Tenancy),
+		UpdateRrSetDetails: dns.UpdateRrSetDetails{Items: items},
+		RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
+	})
+	return err
+}
+
+// 使用 RFC 2136 动态更新记录: 删除同名同类型的记录后添加新记录 (values 为空时只删除), 设置了 tsigKey 时使用 TSIG (RFC 8945) 签名
+func rfc2136Update(server, zone, name, rtype string, ttl int, values []string, tsigKey, tsigAlgorithm string) error {
+	if server == "" {
+		return errors.New("未设置 dnsServer")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	id := uint16(rand.Intn(65536))
+	data, err := buildRfc2136Update(id, zone, name, rtype, ttl, values)
+	if err != nil {
+		return err
+	}
+	if tsigKey != "" {
+		data, err = signTsig(data, tsigKey, tsigAlgorithm, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	conn, err := net.DialTimeout("udp", server, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, err = conn.Write(data)
+	if err != nil {
+		return err
+	}
+	resp := make([]byte, 4096)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return err
+	}
+	if n < 12 || binary.BigEndian.Uint16(resp) != id {
+		return errors.New("DNS 响应错误")
+	}
+	rcodes := []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED", "YXDOMAIN", "YXRRSET", "NXRRSET", "NOTAUTH", "NOTZONE"}
+	if rcode := int(resp[3] & 0x0f); rcode != 0 {
+		if rcode < len(rcodes) {
+			return fmt.Errorf("DNS 更新失败: %s", rcodes[rcode])
+		}
+		return fmt.Errorf("DNS 更新失败: RCODE %d", rcode)
+	}
+	return nil
+}
+
+// 构造 RFC 2136 UPDATE 消息: 删除同名同类型的记录集, 然后逐条添加记录
+func buildRfc2136Update(id uint16, zone, name, rtype string, ttl int, values []string) ([]byte, error) {
+	var typ uint16 = 1
+	if rtype == "AAAA" {
+		typ = 28
+	}
+	var msg bytes.Buffer
+	// 头部: ID, 操作码 UPDATE, 区域数 1, 先决条件数 0, 更新数, 附加记录数 0
+	binary.Write(&msg, binary.BigEndian, []uint16{id, 5 << 11, 1, 0, uint16(1 + len(values)), 0})
+	// 区域: SOA, IN
+	writeDnsName(&msg, zone)
+	binary.Write(&msg, binary.BigEndian, []uint16{6, 1})
+	// 删除记录集: 类型, ANY, TTL 0, 数据长度 0
+	writeDnsName(&msg, name)
+	binary.Write(&msg, binary.BigEndian, []uint16{typ, 255, 0, 0, 0})
+	for _, value := range values {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("IP地址错误: %s", value)
+		}
+		rdata := []byte(ip.To16())
+		if typ == 1 {
+			rdata = ip.To4()
+		}
+		writeDnsName(&msg, name)
+		binary.Write(&msg, binary.BigEndian, []uint16{typ, 1})
+		binary.Write(&msg, binary.BigEndian, uint32(ttl))
+		binary.Write(&msg, binary.BigEndian, uint16(len(rdata)))
+		msg.Write(rdata)
+	}
+	return msg.Bytes(), nil
+}
+
+// 使用 TSIG 签名 DNS 消息, tsigKey 格式为 密钥名称:Base64密钥, now 为签名时间 (Unix 秒)
+func signTsig(msg []byte, tsigKey, algorithm string, now int64) ([]byte, error) {
+	fields := strings.SplitN(tsigKey, ":", 2)
+	if len(fields) != 2 {
+		return nil, errors.New("tsigKey 格式错误, 应为 密钥名称:Base64密钥")
+	}
+	keyName := strings.ToLower(strings.TrimSuffix(fields[0], "."))
+	secret, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("tsigKey 密钥错误: %s", err.Error())
+	}
+	var algName string
+	var hashFunc func() hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "", "hmac-sha256":
+		algName, hashFunc = "hmac-sha256", sha256.New
+	case "hmac-sha1":
+		algName, hashFunc = "hmac-sha1", sha1.New
+	case "hmac-sha512":
+		algName, hashFunc = "hmac-sha512", sha512.New
+	case "hmac-md5":
+		algName, hashFunc = "hmac-md5.sig-alg.reg.int", md5.New
+	default:
+		return nil, fmt.Errorf("不支持的 tsigAlgorithm: %s", algorithm)
+	}
+	var fudge uint16 = 300
+
+	// 签名内容: 消息 + TSIG 变量 (密钥名称, ANY, TTL 0, 算法, 签名时间, 误差, 错误码 0, 其他数据长度 0)
+	var vars bytes.Buffer
+	writeDnsName(&vars, keyName)
+	binary.Write(&vars, binary.BigEndian, uint16(255))
+	binary.Write(&vars, binary.BigEndian, uint32(0))
+	writeDnsName(&vars, algName)
+	binary.Write(&vars, binary.BigEndian, uint16(now>>32))
+	binary.Write(&vars, binary.BigEndian, uint32(now))
+	binary.Write(&vars, binary.BigEndian, []uint16{fudge, 0, 0})
+	mac := hmac.New(hashFunc, secret)
+	mac.Write(msg)
+	mac.Write(vars.Bytes())
+	sum := mac.Sum(nil)
+
+	var rdata bytes.Buffer
+	writeDnsName(&rdata, algName)
+	binary.Write(&rdata, binary.BigEndian, uint16(now>>32))
+	binary.Write(&rdata, binary.BigEndian, uint32(now))
+	binary.Write(&rdata, binary.BigEndian, []uint16{fudge, uint16(len(sum))})
+	rdata.Write(sum)
+	binary.Write(&rdata, binary.BigEndian, []uint16{binary.BigEndian.Uint16(msg), 0, 0})
+
+	signed := bytes.NewBuffer(append([]byte{}, msg...))
+	writeDnsName(signed, keyName)
+	binary.Write(signed, binary.BigEndian, []uint16{250, 255})
+	binary.Write(signed, binary.BigEndian, uint32(0))
+	binary.Write(signed, binary.BigEndian, uint16(rdata.Len()))
+	signed.Write(rdata.Bytes())
+	data := signed.Bytes()
+	// 附加记录数 +1
+	binary.BigEndian.PutUint16(data[10:], binary.BigEndian.Uint16(data[10:])+1)
+	return data, nil
+}
+
+// 写入 DNS 报文格式的域名
+func writeDnsName(buffer *bytes.Buffer, name string) {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		buffer.WriteByte(byte(len(label)))
+		buffer.WriteString(label)
+	}
+	buffer.WriteByte(0)
+}
+
+// 根据 OCID 或名称查找可用且未附加到实例的引导卷
+func findBootVolume(ads []identity.AvailabilityDomain, nameOrId string) (volume core.BootVolume, err error) {
+	if nameOrId == "" {
+		err = errors.New("未设置 bootVolume 参数")
+		return
+	}
+	var found bool
+	if strings.HasPrefix(nameOrId, "ocid1.") {
+		volume, err = getBootVolume(common.String(nameOrId))
+		if err != nil {
+			return
+		}
+		found = true
+	} else {
+		for _, ad := range ads {
+			var volumes []core.BootVolume
+			volumes, err = getBootVolumes(ad.Name)
+			if err != nil {
+				return
+			}
+			for _, v := range volumes {
+				if *v.DisplayName == nameOrId && v.LifecycleState == core.BootVolumeLifecycleStateAvailable {
+					volume, found = v, true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
+	if !found {
+		err = fmt.Errorf("未找到引导卷 %s", nameOrId)
+		return
+	}
+	if volume.LifecycleState != core.BootVolumeLifecycleStateAvailable {
+		err = fmt.Errorf("引导卷 %s 状态为 %s", *volume.DisplayName, volume.LifecycleState)
+		return
+	}
+	attachments, err := listBootVolumeAttachments(volume.AvailabilityDomain, volume.CompartmentId, volume.Id)
+	if err != nil {
+		return
+	}
+	for _, attachment := range attachments {
+		if attachment.LifecycleState == core.BootVolumeAttachmentLifecycleStateAttached || attachment.LifecycleState == core.BootVolumeAttachmentLifecycleStateAttaching {
+			err = fmt.Errorf("引导卷 %s 已附加到实例, 请先分离引导卷", *volume.DisplayName)
+			return
+		}
+	}
+	return
+}
+
+// 在多个可用性域中同时创建实例, 每个可用性域一个请求。已发出的请求不会取消, 超出创建个数的实例由调用方终止
+// 返回创建成功的实例, 以及与 ads 下标对应的错误 (成功的请求为 nil)
+func launchInParallel(request core.LaunchInstanceRequest, ads []identity.AvailabilityDomain) (created []core.Instance, errs []error) {
+	type result struct {
+		index    int
+		instance core.Instance
+		err      error
+	}
+	results := make(chan result, len(ads))
+	for i, ad := range ads {
+		req := request
+		req.AvailabilityDomain = ad.Name
+		token := fmt.Sprintf("%d-%d", time.Now().UnixNano(), i)
+		req.FreeformTags = map[string]string{launchTagKey: token}
+		for k, v := range request.FreeformTags {
+			req.FreeformTags[k] = v
+		}
+		go func(index int, req core.LaunchInstanceRequest, token string) {
+			resp, err := computeClient.LaunchInstance(ctx, req)
+			if _, ok := common.IsServiceError(err); err != nil && !ok {
+				// 网络错误或超时, 服务端可能已经创建了实例
+				printf("\033[1;33m[%s] 创建请求结果未知 (%s), 正在确认实例是否已创建...\033[0m\n", oracleSectionName, err.Error())
+				var found bool
+				resp.Instance, found, err = findLaunchedInstance(req, token)
+				if err == nil && !found {
+					err = errors.New("创建请求失败, 未找到已创建的实例")
+				}
+			}
+			results <- result{index: index, instance: resp.Instance, err: err}
+		}(i, req, token)
+	}
+
+	errs = make([]error, len(ads))
+	for range ads {
+		r := <-results
+		if r.err == nil {
+			created = append(created, r.instance)
+		} else {
+			errs[r.index] = r.err
+		}
+	}
+	return
+}
+
+// 终止同时创建时超出创建个数的实例, 终止失败时发送消息提醒, 需要手动终止
+func terminateSurplusInstance(ins core.Instance) {
+	printf("\033[1;33m[%s] 可用性域 %s 中的实例 %s 超出创建个数, 正在终止...\033[0m\n", oracleSectionName, *ins.AvailabilityDomain, *ins.DisplayName)
+	if err := terminateInstance(ins.Id); err != nil {
+		printlnErr("终止多余的实例失败", err.Error())
+		sendMessage("", fmt.Sprintf("同时创建时多创建了一个实例, 终止失败, 请手动终止\n区域: %s\n实例名称: %s\n可用性域: %s\n实例OCID: %s\n错误信息: %s", oracle.Region, *ins.DisplayName, *ins.AvailabilityDomain, *ins.Id, err.Error()))
+		return
+	}
+	printf("\033[1;32m[%s] 已终止多余的实例 %s\033[0m\n", oracleSectionName, *ins.DisplayName)
+}
+
+// 按实例名称和请求标识查找结果未知的创建请求是否已经创建了实例
+// 服务端接受请求后实例可能需要一段时间才会出现在列表中, 所以在整个查询时间内持续查询, 结束后仍未找到才返回未创建
+// 所有查询都失败时返回 errLaunchUnknown
+func findLaunchedInstance(req core.LaunchInstanceRequest, token string) (ins core.Instance, found bool, err error) {
+	var listed bool
+	for i := 0; i < 6; i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Second)
+		}
+		var resp core.ListInstancesResponse
+		resp, err = computeClient.ListInstances(ctx, core.ListInstancesRequest{
+			CompartmentId:      req.CompartmentId,
+			AvailabilityDomain: req.AvailabilityDomain,
+			DisplayName:        req.DisplayName,
+			RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			continue
+		}
+		listed = true
+		for _, item := range resp.Items {
+			if item.FreeformTags[launchTagKey] == token && item.LifecycleState != core.InstanceLifecycleStateTerminated {
+				return item, true, nil
+			}
+		}
+	}
+	if listed {
+		return ins, false, nil
+	}
+	return ins, false, fmt.Errorf("%w: %s", errLaunchUnknown, err.Error())
+}
+
+func sleepRandomSecond(min, max int32) {
+	var second int32
+	if min <= 0 || max <= 0 {
+		second = 1
+	} else if min >= max {
+		second = max
+	} else {
+		second = rand.Int31n(max-min) + min
+	}
+	sleepSecond(second)
+}
+
+func sleepSecond(second int32) {
+	printf("Sleep %d Second...\n", second)
+	time.Sleep(time.Duration(second) * time.Second)
+}
+
+// 创建实例失败(或成功)后的等待策略
+// random:   在 minTime 和 maxTime 之间随机等待 (默认)
+// fixed:    每次固定等待 minTime 秒
+// backoff:  遇到 429 TooManyRequests 时按指数退避 (带随机抖动) 等待, 最长 backoffMaxTime 秒, 其他情况同 random
+// adaptive: 连续出现 Out of host capacity 时逐步放慢, 在 releaseTimes 附近 (releaseWindow 分钟内) 以 minTime 加快尝试
+type launchPacer struct {
+	strategy        string
+	minTime         int32
+	maxTime         int32
+	backoffMaxTime  int32
+	releaseTimes    []int // 一天中的分钟数
+	releaseWindow   int32
+	tooManyRequests int32 // 连续 429 次数
+	outOfCapacity   int32 // 连续 Out of host capacity 次数
+}
+
+func newLaunchPacer() *launchPacer {
+	p := &launchPacer{
+		strategy:       strings.ToLower(strings.TrimSpace(instance.RetryStrategy)),
+		minTime:        instance.MinTime,
+		maxTime:        instance.MaxTime,
+		backoffMaxTime: instance.BackoffMaxTime,
+		releaseWindow:  instance.ReleaseWindow,
+	}
+	if p.strategy == "" {
+		p.strategy = "random"
+	}
+	if p.backoffMaxTime <= 0 {
+		p.backoffMaxTime = 300
+	}
+	if p.releaseWindow <= 0 {
+		p.releaseWindow = 5
+	}
+	for _, str := range strings.Split(instance.ReleaseTimes, ",") {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			continue
+		}
+		t, err := time.Parse("15:04", str)
+		if err != nil {
+			printlnErr("解析 releaseTimes 失败", err.Error())
+			continue
+		}
+		p.releaseTimes = append(p.releaseTimes, t.Hour()*60+t.Minute())
+	}
+	return p
+}
+
+// 根据本次创建结果等待一段时间, err 为 nil 表示创建成功
+func (p *launchPacer) wait(err error) {
+	isTooManyRequests, isOutOfCapacity := false, false
+	if servErr, ok := common.IsServiceError(err); ok {
+		isTooManyRequests = servErr.GetHTTPStatusCode() == 429 || strings.EqualFold(servErr.GetCode(), "TooManyRequests")
+		isOutOfCapacity = strings.Contains(strings.ToLower(servErr.GetMessage()), "out of host capacity")
+	}
+	if isTooManyRequests {
+		p.tooManyRequests++
+	} else {
+		p.tooManyRequests = 0
+	}
+	if isOutOfCapacity {
+		p.outOfCapacity++
+	} else {
+		p.outOfCapacity = 0
+	}
+
+	switch p.strategy {
+	case "fixed":
+		sleepSecond(p.baseTime())
+	case "backoff":
+		if p.tooManyRequests > 0 {
+			sleepSecond(p.backoff())
+		} else {
+			sleepRandomSecond(p.minTime, p.maxTime)
+		}
+	case "adaptive":
+		if p.tooManyRequests > 0 {
+			sleepSecond(p.backoff())
+		} else if p.inReleaseWindow(time.Now()) {
+			sleepSecond(p.baseTime())
+		} else if p.outOfCapacity > 0 {
+			// 每连续失败一次等待时间增加 50%, 最长 maxTime
+			second := float64(p.baseTime()) * math.Pow(1.5, float64(p.outOfCapacity))
+			if p.maxTime > p.baseTime() && second > float64(p.maxTime) {
+				second = float64(p.maxTime)
+			}
+			sleepSecond(int32(second))
+		} else {
+			sleepRandomSecond(p.minTime, p.maxTime)
+		}
+	default:
+		sleepRandomSecond(p.minTime, p.maxTime)
+	}
+}
+
+func (p *launchPacer) baseTime() int32 {
+	if p.minTime <= 0 {
+		return 1
+	}
+	return p.minTime
+}
+
+// 指数退避: minTime * 2^(n-1), 最长 backoffMaxTime, 在 [一半, 全部] 之间随机抖动
+func (p *launchPacer) backoff() int32 {
+	second := float64(p.baseTime()) * math.Pow(2, float64(p.tooManyRequests-1))
+	if second > float64(p.backoffMaxTime) {
+		second = float64(p.backoffMaxTime)
+	}
+	half := int32(second / 2)
+	if half < 1 {
+		return int32(second)
+	}
+	return half + rand.Int31n(int32(second)-half+1)
+}
+
+// 当前时间是否在容量释放时间点附近
+func (p *launchPacer) inReleaseWindow(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	for _, t := range p.releaseTimes {
+		diff := minute - t
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > 720 {
+			diff = 1440 - diff
+		}
+		if int32(diff) <= p.releaseWindow {
+			return true
+		}
+	}
+	return false
+}
+
+// ExampleLaunchInstance does create an instance
+// NOTE: launch instance will create a new instance and VCN. please make sure delete the instance
+// after execute this sample code, otherwise, you will be charged for the running instance
+func ExampleLaunchInstance() {
+	c, err := core.NewComputeClientWithConfigurationProvider(provider)
+	helpers.FatalIfError(err)
+	networkClient, err := core.NewVirtualNetworkClientWithConfigurationProvider(provider)
+	helpers.FatalIfError(err)
+	ctx := context.Background()
+
+	// create the launch instance request
+	request := core.LaunchInstanceRequest{}
+	request.CompartmentId = common.String(oracle.Tenancy)
+	request.DisplayName = common.String(instance.InstanceDisplayName)
+	request.AvailabilityDomain = common.String(instance.AvailabilityDomain)
+
+	// create a subnet or get the one already created
+	subnet, err := CreateOrGetNetworkInfrastructure(ctx, networkClient)
+	helpers.FatalIfError(err)
 	fmt.Println("subnet created")
 	request.CreateVnicDetails = &core.CreateVnicDetails{SubnetId: subnet.Id}
 
-	// get a image
-	images, err := listImages(ctx, c)
-	helpers.FatalIfError(err)
-	image := images[0]
-	fmt.Println("list images")
-	request.SourceDetails = core.InstanceSourceViaImageDetails{
-		ImageId:             image.Id,
-		BootVolumeSizeInGBs: common.Int64(instance.BootVolumeSizeInGBs),
+	// get a image
+	images, err := listImages(ctx, c)
+	helpers.FatalIfError(err)
+	image := images[0]
+	fmt.Println("list images")
+	request.SourceDetails = core.InstanceSourceViaImageDetails{
+		ImageId:             image.Id,
+		BootVolumeSizeInGBs: common.Int64(instance.BootVolumeSizeInGBs),
+	}
+
+	// use [config.Shape] to create instance
+	request.Shape = common.String(instance.Shape)
+
+	request.ShapeConfig = &core.LaunchInstanceShapeConfigDetails{
+		Ocpus:       common.Float32(instance.Ocpus),
+		MemoryInGBs: common.Float32(instance.MemoryInGBs),
+	}
+
+	// add ssh_authorized_keys
+	//metaData := map[string]string{
+	//	"ssh_authorized_keys": config.SSH_Public_Key,
+	//}
+	//request.Metadata = metaData
+	request.Metadata = map[string]string{"ssh_authorized_keys": instance.SSH_Public_Key}
+
+	// default retry policy will retry on non-200 response
+	request.RequestMetadata = helpers.GetRequestMetadataWithDefaultRetryPolicy()
+
+	createResp, err := c.LaunchInstance(ctx, request)
+	helpers.FatalIfError(err)
+
+	fmt.Println("launching instance")
+
+	// should retry condition check which returns a bool value indicating whether to do retry or not
+	// it checks the lifecycle status equals to Running or not for this case
+	shouldRetryFunc := func(r common.OCIOperationResponse) bool {
+		if converted, ok := r.Response.(core.GetInstanceResponse); ok {
+			return converted.LifecycleState != core.InstanceLifecycleStateRunning
+		}
+		return true
+	}
+
+	// create get instance request with a retry policy which takes a function
+	// to determine shouldRetry or not
+	pollingGetRequest := core.GetInstanceRequest{
+		InstanceId:      createResp.Instance.Id,
+		RequestMetadata: helpers.GetRequestMetadataWithCustomizedRetryPolicy(shouldRetryFunc),
+	}
+
+	instance, pollError := c.GetInstance(ctx, pollingGetRequest)
+	helpers.FatalIfError(pollError)
+
+	fmt.Println("instance launched")
+
+	// 创建辅助 VNIC 并将其附加到指定的实例
+	attachVnicResponse, err := c.AttachVnic(context.Background(), core.AttachVnicRequest{
+		AttachVnicDetails: core.AttachVnicDetails{
+			CreateVnicDetails: &core.CreateVnicDetails{
+				SubnetId:       subnet.Id,
+				AssignPublicIp: common.Bool(true),
+			},
+			InstanceId: instance.Id,
+		},
+	})
+
+	helpers.FatalIfError(err)
+	fmt.Println("vnic attached")
+
+	vnicState := attachVnicResponse.VnicAttachment.LifecycleState
+	for vnicState != core.VnicAttachmentLifecycleStateAttached {
+		time.Sleep(15 * time.Second)
+		getVnicAttachmentRequest, err := c.GetVnicAttachment(context.Background(), core.GetVnicAttachmentRequest{
+			VnicAttachmentId: attachVnicResponse.Id,
+		})
+		helpers.FatalIfError(err)
+		vnicState = getVnicAttachmentRequest.VnicAttachment.LifecycleState
+	}
+
+	// 分离并删除指定的辅助 VNIC
+	_, err = c.DetachVnic(context.Background(), core.DetachVnicRequest{
+		VnicAttachmentId: attachVnicResponse.Id,
+	})
+
+	helpers.FatalIfError(err)
+	fmt.Println("vnic dettached")
+
+	defer func() {
+		terminateInstance(createResp.Id)
+
+		client, clerr := core.NewVirtualNetworkClientWithConfigurationProvider(common.DefaultConfigProvider())
+		helpers.FatalIfError(clerr)
+
+		vcnID := subnet.VcnId
+		deleteSubnet(ctx, client, subnet.Id)
+		deleteVcn(ctx, client, vcnID)
+	}()
+
+	// Output:
+	// subnet created
+	// list images
+	// list shapes
+	// launching instance
+	// instance launched
+	// vnic attached
+	// vnic dettached
+	// terminating instance
+	// instance terminated
+	// deleteing subnet
+	// subnet deleted
+	// deleteing VCN
+	// VCN deleted
+}
+
+func getProvider(oracle Oracle) (common.ConfigurationProvider, error) {
+	content, err := ioutil.ReadFile(oracle.Key_file)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := string(content)
+	privateKeyPassphrase := common.String(oracle.Key_password)
+	return common.NewRawConfigurationProvider(oracle.Tenancy, oracle.User, oracle.Region, oracle.Fingerprint, privateKey, privateKeyPassphrase), nil
+}
+
+// 网络配置: 实例模版中未设置时使用账号中的配置，都未设置时使用默认值
+type networkLayout struct {
+	VcnCidrs       []string
+	VcnDnsLabel    string
+	SubnetCidr     string
+	SubnetDnsLabel string
+	Private        bool // 私有子网, 子网中的 VNIC 不能分配公共IP
+	AdSpecific     bool // 特定于可用性域的子网
+	Firewall       []firewallRule
+	UseNsg         bool   // 防火墙规则添加到网络安全组, 否则添加到新建子网的安全列表
+	NsgName        string // firewallTarget=nsg 时创建或更新的网络安全组名称
+	NsgIds         string // 创建实例时附加的网络安全组 OCID 或名称
+}
+
+var dnsLabelRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]{0,14}$`)
+
+// 读取网络配置参数, 实例模版优先, 其次是账号配置, 最后是默认值
+func networkOption(value, key, def string) string {
+	if value == "" && oracleSection != nil && oracleSection.HasKey(key) {
+		value = oracleSection.Key(key).Value()
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		value = def
+	}
+	return value
+}
+
+// 默认子网: 第一个 VCN 网段开头的 /24, VCN 网段小于 /24 时使用整个网段
+func defaultSubnetCidr(vcnCidr string) string {
+	_, ipNet, err := net.ParseCIDR(vcnCidr)
+	if err != nil {
+		return vcnCidr
+	}
+	if ones, _ := ipNet.Mask.Size(); ones < 24 {
+		ipNet.Mask = net.CIDRMask(24, 32)
+	}
+	return ipNet.String()
+}
+
+// 获取并检查网络配置
+func getNetworkLayout() (layout networkLayout, err error) {
+	for _, cidr := range strings.Split(networkOption(instance.VcnCidr, "vcnCidr", "10.0.0.0/16"), ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		var ipNet *net.IPNet
+		_, ipNet, err = net.ParseCIDR(cidr)
+		if err != nil || ipNet.IP.To4() == nil {
+			err = fmt.Errorf("vcnCidr 格式错误: %s", cidr)
+			return
+		}
+		if ones, _ := ipNet.Mask.Size(); ones < 16 || ones > 30 {
+			err = fmt.Errorf("vcnCidr 前缀长度需要在 /16 和 /30 之间: %s", cidr)
+			return
+		}
+		layout.VcnCidrs = append(layout.VcnCidrs, ipNet.String())
+	}
+	if len(layout.VcnCidrs) == 0 {
+		err = errors.New("vcnCidr 不能为空")
+		return
+	}
+	for i, a := range layout.VcnCidrs {
+		for _, b := range layout.VcnCidrs[i+1:] {
+			if cidrOverlap(a, b) {
+				err = fmt.Errorf("vcnCidr %s 与 %s 重叠", a, b)
+				return
+			}
+		}
+	}
+
+	layout.SubnetCidr = networkOption(instance.SubnetCidr, "subnetCidr", defaultSubnetCidr(layout.VcnCidrs[0]))
+	_, ipNet, e := net.ParseCIDR(layout.SubnetCidr)
+	if e != nil || ipNet.IP.To4() == nil {
+		err = fmt.Errorf("subnetCidr 格式错误: %s", layout.SubnetCidr)
+		return
+	}
+	if ones, _ := ipNet.Mask.Size(); ones > 30 {
+		err = fmt.Errorf("subnetCidr 前缀长度不能大于 /30: %s", layout.SubnetCidr)
+		return
+	}
+	layout.SubnetCidr = ipNet.String()
+
+	// none: 不使用 DNS 主机名
+	layout.VcnDnsLabel = networkOption(instance.VcnDnsLabel, "vcnDnsLabel", "vcndns")
+	layout.SubnetDnsLabel = networkOption(instance.SubnetDnsLabel, "subnetDnsLabel", "subnetdns")
+	for _, label := range []*string{&layout.VcnDnsLabel, &layout.SubnetDnsLabel} {
+		if strings.EqualFold(*label, "none") {
+			*label = ""
+		} else if !dnsLabelRegexp.MatchString(*label) {
+			err = fmt.Errorf("DNS 标签格式错误: %s (以字母开头, 只能包含字母和数字, 最多 15 个字符)", *label)
+			return
+		}
+	}
+
+	switch subnetType := strings.ToLower(networkOption(instance.SubnetType, "subnetType", "public")); subnetType {
+	case "public":
+	case "private":
+		layout.Private = true
+	default:
+		err = fmt.Errorf("不支持的 subnetType: %s", subnetType)
+		return
+	}
+	switch subnetScope := strings.ToLower(networkOption(instance.SubnetScope, "subnetScope", "regional")); subnetScope {
+	case "regional":
+	case "ad":
+		if instance.AvailabilityDomain == "" {
+			err = errors.New("subnetScope=ad 时需要设置 availabilityDomain")
+			return
+		}
+		layout.AdSpecific = true
+	default:
+		err = fmt.Errorf("不支持的 subnetScope: %s", subnetScope)
+		return
+	}
+
+	firewall := networkOption(instance.Firewall, "firewall", "")
+	layout.Firewall, err = loadFirewallRules(firewall)
+	if err != nil {
+		return
+	}
+	switch target := strings.ToLower(networkOption(instance.FirewallTarget, "firewallTarget", "securityList")); target {
+	case "securitylist":
+	case "nsg":
+		if len(layout.Firewall) == 0 {
+			err = errors.New("firewallTarget=nsg 时需要在 FIREWALL 中配置防火墙规则")
+			return
+		}
+		layout.UseNsg = true
+	default:
+		err = fmt.Errorf("不支持的 firewallTarget: %s", target)
+		return
+	}
+	if firewall == "" {
+		firewall = "nsg"
+	}
+	layout.NsgName = networkOption(instance.NsgName, "nsgName", "oci-help-"+firewall)
+	layout.NsgIds = networkOption(instance.NsgIds, "nsgIds", "")
+	return
+}
+
+// 子网说明, 用于显示 dry run 计划
+func (layout networkLayout) subnetDesc(displayName string) string {
+	desc := fmt.Sprintf("%s %s", displayName, layout.SubnetCidr)
+	if layout.SubnetDnsLabel != "" {
+		desc += fmt.Sprintf(" (DNS: %s)", layout.SubnetDnsLabel)
 	}
+	if layout.Private {
+		desc += " 私有子网"
+	} else {
+		desc += " 公共子网"
+	}
+	if layout.AdSpecific {
+		desc += " 可用性域: " + instance.AvailabilityDomain
+	} else {
+		desc += " 区域性子网"
+	}
+	return desc
+}
 
-	// use [config.Shape] to create instance
-	request.Shape = common.String(instance.Shape)
+// 两个 CIDR 是否重叠
+func cidrOverlap(a, b string) bool {
+	_, na, err := net.ParseCIDR(a)
+	if err != nil {
+		return false
+	}
+	_, nb, err := net.ParseCIDR(b)
+	if err != nil {
+		return false
+	}
+	return na.Contains(nb.IP) || nb.Contains(na.IP)
+}
 
-	request.ShapeConfig = &core.LaunchInstanceShapeConfigDetails{
-		Ocpus:       common.Float32(instance.Ocpus),
-		MemoryInGBs: common.Float32(instance.MemoryInGBs),
+// CIDR 是否在 VCN 的地址范围内
+func cidrWithin(cidr string, vcnCidrs []string) bool {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
 	}
+	ones, _ := n.Mask.Size()
+	for _, vcnCidr := range vcnCidrs {
+		_, vn, err := net.ParseCIDR(vcnCidr)
+		if err != nil {
+			continue
+		}
+		vOnes, _ := vn.Mask.Size()
+		if vn.Contains(n.IP) && ones >= vOnes {
+			return true
+		}
+	}
+	return false
+}
 
-	// add ssh_authorized_keys
-	//metaData := map[string]string{
-	//	"ssh_authorized_keys": config.SSH_Public_Key,
-	//}
-	//request.Metadata = metaData
-	request.Metadata = map[string]string{"ssh_authorized_keys": instance.SSH_Public_Key}
+// VCN 的 CIDR 列表
+func vcnCidrBlocks(vcn core.Vcn) []string {
+	if len(vcn.CidrBlocks) > 0 {
+		return vcn.CidrBlocks
+	}
+	if vcn.CidrBlock != nil {
+		return []string{*vcn.CidrBlock}
+	}
+	return nil
+}
 
-	// default retry policy will retry on non-200 response
-	request.RequestMetadata = helpers.GetRequestMetadataWithDefaultRetryPolicy()
+// 检查要创建的子网是否与 VCN 和已有子网冲突
+func checkSubnetLayout(vcnCidrs []string, vcnDnsLabel *string, subnets []core.Subnet, layout *networkLayout) error {
+	if !cidrWithin(layout.SubnetCidr, vcnCidrs) {
+		return fmt.Errorf("子网 CIDR %s 不在 VCN 的地址范围 %s 内, 请设置 subnetCidr", layout.SubnetCidr, strings.Join(vcnCidrs, ","))
+	}
+	for _, s := range subnets {
+		if s.CidrBlock != nil && cidrOverlap(layout.SubnetCidr, *s.CidrBlock) {
+			return fmt.Errorf("子网 CIDR %s 与已有子网 %s (%s) 重叠, 请设置 subnetCidr 或 subnetDisplayName", layout.SubnetCidr, *s.DisplayName, *s.CidrBlock)
+		}
+		if layout.SubnetDnsLabel != "" && s.DnsLabel != nil && strings.EqualFold(*s.DnsLabel, layout.SubnetDnsLabel) {
+			return fmt.Errorf("子网 DNS 标签 %s 已被子网 %s 使用, 请设置 subnetDnsLabel", layout.SubnetDnsLabel, *s.DisplayName)
+		}
+	}
+	if layout.SubnetDnsLabel != "" && (vcnDnsLabel == nil || *vcnDnsLabel == "") {
+		// VCN 未启用 DNS 主机名时子网不能设置 DNS 标签
+		fmt.Printf("VCN 未设置 DNS 标签, 子网不使用 DNS 标签\n")
+		layout.SubnetDnsLabel = ""
+	}
+	return nil
+}
 
-	createResp, err := c.LaunchInstance(ctx, request)
-	helpers.FatalIfError(err)
+// 子网是否符合网络配置
+func subnetMatches(s core.Subnet, layout networkLayout) bool {
+	private := s.ProhibitPublicIpOnVnic != nil && *s.ProhibitPublicIpOnVnic
+	if private != layout.Private {
+		return false
+	}
+	adSpecific := s.AvailabilityDomain != nil && *s.AvailabilityDomain != ""
+	if adSpecific && *s.AvailabilityDomain != instance.AvailabilityDomain {
+		// 特定于可用性域的子网只能在该可用性域中创建实例
+		return false
+	}
+	return adSpecific == layout.AdSpecific
+}
 
-	fmt.Println("launching instance")
+// 创建或获取基础网络设施
+func CreateOrGetNetworkInfrastructure(ctx context.Context, c core.VirtualNetworkClient) (subnet core.Subnet, err error) {
+	var layout networkLayout
+	layout, err = getNetworkLayout()
+	if err != nil {
+		return
+	}
+	var vcn core.Vcn
+	vcn, err = createOrGetVcn(ctx, c, layout)
+	if err != nil {
+		return
+	}
+	if vcn.Id == nil {
+		// dry run 模式下 VCN 尚未创建, Internet 网关、路由规则和子网也需要创建
+		err = checkSubnetLayout(layout.VcnCidrs, vcn.DnsLabel, nil, &layout)
+		if err != nil {
+			return
+		}
+		subnet.DisplayName = common.String(instance.SubnetDisplayName)
+		if *subnet.DisplayName == "" {
+			subnet.DisplayName = common.String(time.Now().Format("subnet-20060102-1504"))
+		}
+		if layout.Private {
+			networkPlan = append(networkPlan, "创建路由表: "+*subnet.DisplayName+"-rt (无路由规则)")
+		} else {
+			networkPlan = append(networkPlan,
+				"创建Internet网关",
+				"添加路由规则: 0.0.0.0/0 -> Internet网关")
+		}
+		networkPlan = append(networkPlan,
+			"创建子网: "+layout.subnetDesc(*subnet.DisplayName),
+			layout.securityListPlan())
+		return
+	}
+	if !layout.Private {
+		var gateway core.InternetGateway
+		gateway, err = createOrGetInternetGateway(c, vcn.Id)
+		if err != nil {
+			return
+		}
+		_, err = createOrGetRouteTable(c, gateway.Id, vcn.Id)
+		if err != nil {
+			return
+		}
+	}
+	subnet, err = createOrGetSubnetWithDetails(ctx, c, vcn,
+		common.String(instance.SubnetDisplayName), layout)
+	return
+}
 
-	// should retry condition check which returns a bool value indicating whether to do retry or not
-	// it checks the lifecycle status equals to Running or not for this case
-	shouldRetryFunc := func(r common.OCIOperationResponse) bool {
-		if converted, ok := r.Response.(core.GetInstanceResponse); ok {
-			return converted.LifecycleState != core.InstanceLifecycleStateRunning
+// CreateOrGetSubnetWithDetails either creates a new Virtual Cloud Network (VCN) or get the one already exist
+// with detail info
+func createOrGetSubnetWithDetails(ctx context.Context, c core.VirtualNetworkClient, vcn core.Vcn,
+	displayName *string, layout networkLayout) (subnet core.Subnet, err error) {
+	var subnets []core.Subnet
+	subnets, err = listSubnets(ctx, c, vcn.Id)
+	if err != nil {
+		return
+	}
+
+	if displayName == nil {
+		displayName = common.String(instance.SubnetDisplayName)
+	}
+
+	// 未指定子网名称时，使用符合网络配置的子网
+	if *displayName == "" {
+		for _, element := range subnets {
+			if subnetMatches(element, layout) {
+				subnet = element
+				return
+			}
+		}
+	}
+
+	// check if the subnet has already been created
+	for _, element := range subnets {
+		if *element.DisplayName == *displayName {
+			// find the subnet, return it
+			if element.AvailabilityDomain != nil && *element.AvailabilityDomain != "" && *element.AvailabilityDomain != instance.AvailabilityDomain {
+				err = fmt.Errorf("子网 %s 特定于可用性域 %s, 请将 availabilityDomain 设置为该可用性域", *displayName, *element.AvailabilityDomain)
+				return
+			}
+			subnet = element
+			return
+		}
+	}
+
+	// create a new subnet
+	// 子网名称为空，以当前时间为名称创建子网
+	if *displayName == "" {
+		displayName = common.String(time.Now().Format("subnet-20060102-1504"))
+	}
+	err = checkSubnetLayout(vcnCidrBlocks(vcn), vcn.DnsLabel, subnets, &layout)
+	if err != nil {
+		return
+	}
+	if isDryRun() {
+		if layout.Private {
+			networkPlan = append(networkPlan, "创建路由表: "+*displayName+"-rt (无路由规则)")
+		}
+		networkPlan = append(networkPlan,
+			"创建子网: "+layout.subnetDesc(*displayName),
+			layout.securityListPlan())
+		subnet.DisplayName = displayName
+		// 子网尚未创建, 记录所在的 VCN 以便查找网络安全组
+		subnet.VcnId = vcn.Id
+		return
+	}
+	fmt.Printf("开始创建Subnet（没有可用的Subnet，或指定的Subnet不存在）\n")
+	request := core.CreateSubnetRequest{}
+	// 省略此属性创建区域性子网(regional subnet)，提供此属性创建特定于可用性域的子网。建议创建区域性子网。
+	if layout.AdSpecific {
+		request.AvailabilityDomain = common.String(instance.AvailabilityDomain)
+	}
+	request.CompartmentId = &oracle.Tenancy
+	request.CidrBlock = common.String(layout.SubnetCidr)
+	request.DisplayName = displayName
+	if layout.SubnetDnsLabel != "" {
+		request.DnsLabel = common.String(layout.SubnetDnsLabel)
+	}
+	if layout.Private {
+		// 私有子网不能使用包含 Internet 网关路由规则的默认路由表
+		var rt core.RouteTable
+		rt, err = createPrivateRouteTable(ctx, c, vcn.Id, *displayName+"-rt")
+		if err != nil {
+			return
+		}
+		request.RouteTableId = rt.Id
+		request.ProhibitPublicIpOnVnic = common.Bool(true)
+	}
+	request.RequestMetadata = getCustomRequestMetadataWithRetryPolicy()
+
+	request.VcnId = vcn.Id
+	var r core.CreateSubnetResponse
+	r, err = c.CreateSubnet(ctx, request)
+	if err != nil {
+		return
+	}
+	// retry condition check, stop unitl return true
+	pollUntilAvailable := func(r common.OCIOperationResponse) bool {
+		if converted, ok := r.Response.(core.GetSubnetResponse); ok {
+			return converted.LifecycleState != core.SubnetLifecycleStateAvailable
 		}
 		return true
 	}
 
-	// create get instance request with a retry policy which takes a function
-	// to determine shouldRetry or not
-	pollingGetRequest := core.GetInstanceRequest{
-		InstanceId:      createResp.Instance.Id,
-		RequestMetadata: helpers.GetRequestMetadataWithCustomizedRetryPolicy(shouldRetryFunc),
+	pollGetRequest := core.GetSubnetRequest{
+		SubnetId:        r.Id,
+		RequestMetadata: helpers.GetRequestMetadataWithCustomizedRetryPolicy(pollUntilAvailable),
+	}
+
+	// wait for lifecyle become running
+	_, err = c.GetSubnet(ctx, pollGetRequest)
+	if err != nil {
+		return
+	}
+
+	// update the security rules
+	err = applySubnetSecurityList(ctx, c, common.String(r.SecurityListIds[0]), layout)
+	if err != nil {
+		return
+	}
+	fmt.Printf("Subnet创建成功: %s\n", *r.Subnet.DisplayName)
+	subnet = r.Subnet
+	return
+}
+
+// 列出指定虚拟云网络 (VCN) 中的所有子网
+func listSubnets(ctx context.Context, c core.VirtualNetworkClient, vcnID *string) (subnets []core.Subnet, err error) {
+	request := core.ListSubnetsRequest{
+		CompartmentId:   &oracle.Tenancy,
+		VcnId:           vcnID,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	var r core.ListSubnetsResponse
+	r, err = c.ListSubnets(ctx, request)
+	if err != nil {
+		return
+	}
+	subnets = r.Items
+	return
+}
+
+// 创建一个新的虚拟云网络 (VCN) 或获取已经存在的虚拟云网络
+func createOrGetVcn(ctx context.Context, c core.VirtualNetworkClient, layout networkLayout) (core.Vcn, error) {
+	var vcn core.Vcn
+	vcnItems, err := listVcns(ctx, c)
+	if err != nil {
+		return vcn, err
+	}
+	displayName := common.String(instance.VcnDisplayName)
+	if len(vcnItems) > 0 && *displayName == "" {
+		vcn = vcnItems[0]
+		return vcn, err
+	}
+	for _, element := range vcnItems {
+		if *element.DisplayName == instance.VcnDisplayName {
+			// VCN already created, return it
+			vcn = element
+			return vcn, err
+		}
+	}
+	// create a new VCN
+	if *displayName == "" {
+		displayName = common.String(time.Now().Format("vcn-20060102-1504"))
+	}
+	for _, element := range vcnItems {
+		for _, cidr := range vcnCidrBlocks(element) {
+			for _, newCidr := range layout.VcnCidrs {
+				if cidrOverlap(cidr, newCidr) {
+					// 地址重叠的 VCN 之间无法建立对等连接
+					fmt.Printf("\033[1;33m注意: VCN CIDR %s 与已有 VCN %s (%s) 重叠, 两个 VCN 之间无法建立对等连接\033[0m\n", newCidr, *element.DisplayName, cidr)
+				}
+			}
+		}
+		if layout.VcnDnsLabel != "" && element.DnsLabel != nil && strings.EqualFold(*element.DnsLabel, layout.VcnDnsLabel) {
+			return vcn, fmt.Errorf("VCN DNS 标签 %s 已被 VCN %s 使用, 请设置 vcnDnsLabel", layout.VcnDnsLabel, *element.DisplayName)
+		}
+	}
+	if layout.VcnDnsLabel != "" {
+		vcn.DnsLabel = common.String(layout.VcnDnsLabel)
+	}
+	if isDryRun() {
+		plan := fmt.Sprintf("创建VCN: %s %s", *displayName, strings.Join(layout.VcnCidrs, ","))
+		if layout.VcnDnsLabel != "" {
+			plan += fmt.Sprintf(" (DNS: %s)", layout.VcnDnsLabel)
+		}
+		networkPlan = append(networkPlan, plan)
+		vcn.DisplayName = displayName
+		return vcn, nil
+	}
+	fmt.Println("开始创建VCN（没有可用的VCN，或指定的VCN不存在）")
+	request := core.CreateVcnRequest{}
+	request.RequestMetadata = getCustomRequestMetadataWithRetryPolicy()
+	request.CidrBlocks = layout.VcnCidrs
+	request.CompartmentId = common.String(oracle.Tenancy)
+	request.DisplayName = displayName
+	request.DnsLabel = vcn.DnsLabel
+	r, err := c.CreateVcn(ctx, request)
+	if err != nil {
+		return vcn, err
+	}
+	fmt.Printf("VCN创建成功: %s\n", *r.Vcn.DisplayName)
+	vcn = r.Vcn
+	return vcn, err
+}
+
+// 列出所有虚拟云网络 (VCN)
+func listVcns(ctx context.Context, c core.VirtualNetworkClient) ([]core.Vcn, error) {
+	request := core.ListVcnsRequest{
+		CompartmentId:   &oracle.Tenancy,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	r, err := c.ListVcns(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return r.Items, err
+}
+
+// 创建或者获取 Internet 网关
+func createOrGetInternetGateway(c core.VirtualNetworkClient, vcnID *string) (core.InternetGateway, error) {
+	//List Gateways
+	var gateway core.InternetGateway
+	listGWRequest := core.ListInternetGatewaysRequest{
+		CompartmentId:   &oracle.Tenancy,
+		VcnId:           vcnID,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+
+	listGWRespone, err := c.ListInternetGateways(ctx, listGWRequest)
+	if err != nil {
+		fmt.Printf("Internet gateway list error: %s\n", err.Error())
+		return gateway, err
 	}
 
-	instance, pollError := c.GetInstance(ctx, pollingGetRequest)
-	helpers.FatalIfError(pollError)
-
-	fmt.Println("instance launched")
+	if len(listGWRespone.Items) >= 1 {
+		//Gateway with name already exists
+		gateway = listGWRespone.Items[0]
+	} else if isDryRun() {
+		networkPlan = append(networkPlan, "创建Internet网关")
+	} else {
+		//Create new Gateway
+		fmt.Printf("开始创建Internet网关\n")
+		enabled := true
+		createGWDetails := core.CreateInternetGatewayDetails{
+			CompartmentId: &oracle.Tenancy,
+			IsEnabled:     &enabled,
+			VcnId:         vcnID,
+		}
 
-	// 创建辅助 VNIC 并将其附加到指定的实例
-	attachVnicResponse, err := c.AttachVnic(context.Background(), core.AttachVnicRequest{
-		AttachVnicDetails: core.AttachVnicDetails{
-			CreateVnicDetails: &core.CreateVnicDetails{
-				SubnetId:       subnet.Id,
-				AssignPublicIp: common.Bool(true),
-			},
-			InstanceId: instance.Id,
-		},
-	})
+		createGWRequest := core.CreateInternetGatewayRequest{
+			CreateInternetGatewayDetails: createGWDetails,
+			RequestMetadata:              getCustomRequestMetadataWithRetryPolicy()}
 
-	helpers.FatalIfError(err)
-	fmt.Println("vnic attached")
+		createGWResponse, err := c.CreateInternetGateway(ctx, createGWRequest)
 
-	vnicState := attachVnicResponse.VnicAttachment.LifecycleState
-	for vnicState != core.VnicAttachmentLifecycleStateAttached {
-		time.Sleep(15 * time.Second)
-		getVnicAttachmentRequest, err := c.GetVnicAttachment(context.Background(), core.GetVnicAttachmentRequest{
-			VnicAttachmentId: attachVnicResponse.Id,
-		})
-		helpers.FatalIfError(err)
-		vnicState = getVnicAttachmentRequest.VnicAttachment.LifecycleState
+		if err != nil {
+			fmt.Printf("Internet gateway create error: %s\n", err.Error())
+			return gateway, err
+		}
+		gateway = createGWResponse.InternetGateway
+		fmt.Printf("Internet网关创建成功: %s\n", *gateway.DisplayName)
 	}
+	return gateway, err
+}
 
-	// 分离并删除指定的辅助 VNIC
-	_, err = c.DetachVnic(context.Background(), core.DetachVnicRequest{
-		VnicAttachmentId: attachVnicResponse.Id,
-	})
-
-	helpers.FatalIfError(err)
-	fmt.Println("vnic dettached")
-
-	defer func() {
-		terminateInstance(createResp.Id)
+// 创建或者获取路由表
+func createOrGetRouteTable(c core.VirtualNetworkClient, gatewayID, VcnID *string) (routeTable core.RouteTable, err error) {
+	//List Route Table
+	listRTRequest := core.ListRouteTablesRequest{
+		CompartmentId:   &oracle.Tenancy,
+		VcnId:           VcnID,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	var listRTResponse core.ListRouteTablesResponse
+	listRTResponse, err = c.ListRouteTables(ctx, listRTRequest)
+	if err != nil {
+		fmt.Printf("Route table list error: %s\n", err.Error())
+		return
+	}
 
-		client, clerr := core.NewVirtualNetworkClientWithConfigurationProvider(common.DefaultConfigProvider())
-		helpers.FatalIfError(clerr)
+	cidrRange := "0.0.0.0/0"
+	rr := core.RouteRule{
+		NetworkEntityId: gatewayID,
+		Destination:     &cidrRange,
+		DestinationType: core.RouteRuleDestinationTypeCidrBlock,
+	}
 
-		vcnID := subnet.VcnId
-		deleteSubnet(ctx, client, subnet.Id)
-		deleteVcn(ctx, client, vcnID)
-	}()
+	if len(listRTResponse.Items) >= 1 {
+		//Default Route Table found and has at least 1 route rule
+		if len(listRTResponse.Items[0].RouteRules) >= 1 {
+			routeTable = listRTResponse.Items[0]
+			//Default Route table needs route rule adding
+		} else if isDryRun() {
+			networkPlan = append(networkPlan, "添加路由规则: 0.0.0.0/0 -> Internet网关")
+			routeTable = listRTResponse.Items[0]
+		} else {
+			fmt.Printf("路由表未添加规则，开始添加Internet路由规则\n")
+			updateRTDetails := core.UpdateRouteTableDetails{
+				RouteRules: []core.RouteRule{rr},
+			}
 
-	// Output:
-	// subnet created
-	// list images
-	// list shapes
-	// launching instance
-	// instance launched
-	// vnic attached
-	// vnic dettached
-	// terminating instance
-	// instance terminated
-	// deleteing subnet
-	// subnet deleted
-	// deleteing VCN
-	// VCN deleted
-}
+			updateRTRequest := core.UpdateRouteTableRequest{
+				RtId:                    listRTResponse.Items[0].Id,
+				UpdateRouteTableDetails: updateRTDetails,
+				RequestMetadata:         getCustomRequestMetadataWithRetryPolicy(),
+			}
+			var updateRTResponse core.UpdateRouteTableResponse
+			updateRTResponse, err = c.UpdateRouteTable(ctx, updateRTRequest)
+			if err != nil {
+				fmt.Printf("Error updating route table: %s\n", err)
+				return
+			}
+			fmt.Printf("Internet路由规则添加成功\n")
+			routeTable = updateRTResponse.RouteTable
+		}
 
-func getProvider(oracle Oracle) (common.ConfigurationProvider, error) {
-	content, err := ioutil.ReadFile(oracle.Key_file)
-	if err != nil {
-		return nil, err
+	} else {
+		//No default route table found
+		fmt.Printf("Error could not find VCN default route table, VCN OCID: %s Could not find route table.\n", *VcnID)
 	}
-	privateKey := string(content)
-	privateKeyPassphrase := common.String(oracle.Key_password)
-	return common.NewRawConfigurationProvider(oracle.Tenancy, oracle.User, oracle.Region, oracle.Fingerprint, privateKey, privateKeyPassphrase), nil
+	return
 }
 
-// 创建或获取基础网络设施
-func CreateOrGetNetworkInfrastructure(ctx context.Context, c core.VirtualNetworkClient) (subnet core.Subnet, err error) {
-	var vcn core.Vcn
-	vcn, err = createOrGetVcn(ctx, c)
+// 创建或获取私有子网使用的路由表 (没有路由规则)
+func createPrivateRouteTable(ctx context.Context, c core.VirtualNetworkClient, vcnID *string, displayName string) (routeTable core.RouteTable, err error) {
+	var listResp core.ListRouteTablesResponse
+	listResp, err = c.ListRouteTables(ctx, core.ListRouteTablesRequest{
+		CompartmentId:   &oracle.Tenancy,
+		VcnId:           vcnID,
+		DisplayName:     common.String(displayName),
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
 	if err != nil {
 		return
 	}
-	var gateway core.InternetGateway
-	gateway, err = createOrGetInternetGateway(c, vcn.Id)
-	if err != nil {
+	if len(listResp.Items) > 0 {
+		routeTable = listResp.Items[0]
 		return
 	}
-	_, err = createOrGetRouteTable(c, gateway.Id, vcn.Id)
+	fmt.Printf("开始创建路由表: %s\n", displayName)
+	var createResp core.CreateRouteTableResponse
+	createResp, err = c.CreateRouteTable(ctx, core.CreateRouteTableRequest{
+		CreateRouteTableDetails: core.CreateRouteTableDetails{
+			CompartmentId: &oracle.Tenancy,
+			VcnId:         vcnID,
+			DisplayName:   common.String(displayName),
+			RouteRules:    []core.RouteRule{},
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
 	if err != nil {
 		return
 	}
-	subnet, err = createOrGetSubnetWithDetails(
-		ctx, c, vcn.Id,
-		common.String(instance.SubnetDisplayName),
-		common.String("10.0.0.0/20"),
-		common.String("subnetdns"),
-		common.String(instance.AvailabilityDomain))
+	routeTable = createResp.RouteTable
 	return
 }
 
-// CreateOrGetSubnetWithDetails either creates a new Virtual Cloud Network (VCN) or get the one already exist
-// with detail info
-func createOrGetSubnetWithDetails(ctx context.Context, c core.VirtualNetworkClient, vcnID *string,
-	displayName *string, cidrBlock *string, dnsLabel *string, availableDomain *string) (subnet core.Subnet, err error) {
-	var subnets []core.Subnet
-	subnets, err = listSubnets(ctx, c, vcnID)
-	if err != nil {
+// 防火墙入站规则, 配置格式: 协议/端口|来源|说明
+type firewallRule struct {
+	Name        string
+	Protocol    string // all / tcp / udp / icmp / icmpv6 或协议号
+	MinPort     int    // tcp/udp 目标端口范围, 0 表示所有端口
+	MaxPort     int
+	IcmpType    int // -1 表示所有类型
+	IcmpCode    int // -1 表示所有代码
+	Source      string
+	Description string
+}
+
+// 协议名称和 OCI 协议号
+var firewallProtocols = map[string]string{
+	"all":    "all",
+	"icmp":   "1",
+	"tcp":    "6",
+	"udp":    "17",
+	"icmpv6": "58",
+}
+
+// 解析防火墙规则, 例如 tcp/22|203.0.113.0/24|SSH、udp/51820-51830、icmp/3:4、all|10.0.0.0/16
+func parseFirewallRule(name, value string) (rule firewallRule, err error) {
+	fields := strings.Split(value, "|")
+	if len(fields) > 3 {
+		err = fmt.Errorf("规则 %s 格式错误, 应为 协议/端口|来源|说明", name)
+		return
+	}
+	rule = firewallRule{Name: name, Source: "0.0.0.0/0", IcmpType: -1, IcmpCode: -1}
+	protocol := strings.ToLower(strings.TrimSpace(fields[0]))
+	var ports string
+	if i := strings.Index(protocol, "/"); i >= 0 {
+		protocol, ports = protocol[:i], strings.TrimSpace(protocol[i+1:])
+	}
+	rule.Protocol = protocol
+	switch protocol {
+	case "all":
+		if ports != "" {
+			err = fmt.Errorf("规则 %s 错误: all 不能指定端口", name)
+			return
+		}
+	case "tcp", "udp":
+		if ports != "" && ports != "*" {
+			bounds := strings.SplitN(ports, "-", 2)
+			rule.MinPort, err = strconv.Atoi(strings.TrimSpace(bounds[0]))
+			rule.MaxPort = rule.MinPort
+			if err == nil && len(bounds) == 2 {
+				rule.MaxPort, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			}
+			if err != nil || rule.MinPort < 1 || rule.MaxPort > 65535 || rule.MinPort > rule.MaxPort {
+				err = fmt.Errorf("规则 %s 端口错误: %s", name, ports)
+				return
+			}
+		}
+	case "icmp", "icmpv6":
+		if ports != "" && ports != "*" {
+			values := strings.SplitN(ports, ":", 2)
+			rule.IcmpType, err = strconv.Atoi(strings.TrimSpace(values[0]))
+			if err == nil && len(values) == 2 {
+				rule.IcmpCode, err = strconv.Atoi(strings.TrimSpace(values[1]))
+			}
+			if err != nil || rule.IcmpType < 0 || rule.IcmpType > 255 || (len(values) == 2 && rule.IcmpCode < 0) || rule.IcmpCode > 255 {
+				err = fmt.Errorf("规则 %s ICMP 类型错误: %s", name, ports)
+				return
+			}
+		}
+	default:
+		err = fmt.Errorf("规则 %s 协议错误: %s (支持 all、tcp、udp、icmp、icmpv6)", name, protocol)
 		return
 	}
+	if len(fields) > 1 {
+		if source := strings.TrimSpace(fields[1]); source != "" {
+			var ipNet *net.IPNet
+			_, ipNet, err = net.ParseCIDR(source)
+			if err != nil {
+				err = fmt.Errorf("规则 %s 来源错误: %s", name, source)
+				return
+			}
+			rule.Source = ipNet.String()
+		}
+	}
+	if len(fields) > 2 {
+		rule.Description = strings.TrimSpace(fields[2])
+	}
+	return
+}
 
-	if displayName == nil {
-		displayName = common.String(instance.SubnetDisplayName)
+// 协议和端口, 例如 tcp/22、udp/51820-51830、icmp/3:4
+func (r firewallRule) String() string {
+	s := r.Protocol
+	switch r.Protocol {
+	case "tcp", "udp":
+		if r.MinPort > 0 && r.MinPort == r.MaxPort {
+			s += fmt.Sprintf("/%d", r.MinPort)
+		} else if r.MinPort > 0 {
+			s += fmt.Sprintf("/%d-%d", r.MinPort, r.MaxPort)
+		}
+	case "icmp", "icmpv6":
+		if r.IcmpType >= 0 {
+			s += fmt.Sprintf("/%d", r.IcmpType)
+			if r.IcmpCode >= 0 {
+				s += fmt.Sprintf(":%d", r.IcmpCode)
+			}
+		}
 	}
+	return s
+}
 
-	if len(subnets) > 0 && *displayName == "" {
-		subnet = subnets[0]
-		return
+// 用于比较规则是否相同 (不比较说明)
+func (r firewallRule) key() string {
+	return r.String() + "|" + r.Source
+}
+
+func (r firewallRule) options() (tcp *core.TcpOptions, udp *core.UdpOptions, icmp *core.IcmpOptions) {
+	var portRange *core.PortRange
+	if r.MinPort > 0 {
+		portRange = &core.PortRange{Min: common.Int(r.MinPort), Max: common.Int(r.MaxPort)}
+	}
+	switch r.Protocol {
+	case "tcp":
+		if portRange != nil {
+			tcp = &core.TcpOptions{DestinationPortRange: portRange}
+		}
+	case "udp":
+		if portRange != nil {
+			udp = &core.UdpOptions{DestinationPortRange: portRange}
+		}
+	case "icmp", "icmpv6":
+		if r.IcmpType >= 0 {
+			icmp = &core.IcmpOptions{Type: common.Int(r.IcmpType)}
+			if r.IcmpCode >= 0 {
+				icmp.Code = common.Int(r.IcmpCode)
+			}
+		}
 	}
+	return
+}
 
-	// check if the subnet has already been created
-	for _, element := range subnets {
-		if *element.DisplayName == *displayName {
-			// find the subnet, return it
-			subnet = element
-			return
+func (r firewallRule) protocolNumber() *string {
+	if number, ok := firewallProtocols[r.Protocol]; ok {
+		return common.String(number)
+	}
+	return common.String(r.Protocol)
+}
+
+func (r firewallRule) description() *string {
+	if r.Description == "" {
+		return nil
+	}
+	return common.String(r.Description)
+}
+
+// 转换为安全列表入站规则
+func (r firewallRule) ingressSecurityRule() core.IngressSecurityRule {
+	tcp, udp, icmp := r.options()
+	return core.IngressSecurityRule{
+		Protocol:    r.protocolNumber(),
+		Source:      common.String(r.Source),
+		SourceType:  core.IngressSecurityRuleSourceTypeCidrBlock,
+		TcpOptions:  tcp,
+		UdpOptions:  udp,
+		IcmpOptions: icmp,
+		Description: r.description(),
+	}
+}
+
+// 转换为网络安全组入站规则
+func (r firewallRule) addSecurityRuleDetails() core.AddSecurityRuleDetails {
+	tcp, udp, icmp := r.options()
+	return core.AddSecurityRuleDetails{
+		Direction:   core.AddSecurityRuleDetailsDirectionIngress,
+		Protocol:    r.protocolNumber(),
+		Source:      common.String(r.Source),
+		SourceType:  core.AddSecurityRuleDetailsSourceTypeCidrBlock,
+		TcpOptions:  tcp,
+		UdpOptions:  udp,
+		IcmpOptions: icmp,
+		Description: r.description(),
+	}
+}
+
+// 由安全列表或网络安全组中的规则创建防火墙规则
+func newFirewallRule(protocol, source *string, tcp *core.TcpOptions, udp *core.UdpOptions, icmp *core.IcmpOptions, description *string) firewallRule {
+	rule := firewallRule{IcmpType: -1, IcmpCode: -1}
+	if protocol != nil {
+		rule.Protocol = *protocol
+		for name, number := range firewallProtocols {
+			if number == *protocol {
+				rule.Protocol = name
+			}
+		}
+	}
+	if source != nil {
+		rule.Source = *source
+	}
+	var portRange *core.PortRange
+	if tcp != nil {
+		portRange = tcp.DestinationPortRange
+	} else if udp != nil {
+		portRange = udp.DestinationPortRange
+	}
+	if portRange != nil && portRange.Min != nil && portRange.Max != nil {
+		rule.MinPort, rule.MaxPort = *portRange.Min, *portRange.Max
+	}
+	if icmp != nil && icmp.Type != nil {
+		rule.IcmpType = *icmp.Type
+		if icmp.Code != nil {
+			rule.IcmpCode = *icmp.Code
 		}
 	}
+	if description != nil {
+		rule.Description = *description
+	}
+	return rule
+}
 
-	// create a new subnet
-	fmt.Printf("开始创建Subnet（没有可用的Subnet，或指定的Subnet不存在）\n")
-	// 子网名称为空，以当前时间为名称创建子网
-	if *displayName == "" {
-		displayName = common.String(time.Now().Format("subnet-20060102-1504"))
+func firewallRuleFromIngress(r core.IngressSecurityRule) firewallRule {
+	return newFirewallRule(r.Protocol, r.Source, r.TcpOptions, r.UdpOptions, r.IcmpOptions, r.Description)
+}
+
+func firewallRuleFromSecurityRule(r core.SecurityRule) firewallRule {
+	return newFirewallRule(r.Protocol, r.Source, r.TcpOptions, r.UdpOptions, r.IcmpOptions, r.Description)
+}
+
+// 规则说明, 用于显示 dry run 计划
+func firewallRulesDesc(rules []firewallRule) string {
+	var items []string
+	for _, r := range rules {
+		items = append(items, r.String()+" "+r.Source)
 	}
-	request := core.CreateSubnetRequest{}
-	//request.AvailabilityDomain = availableDomain //省略此属性创建区域性子网(regional subnet)，提供此属性创建特定于可用性域的子网。建议创建区域性子网。
-	request.CompartmentId = &oracle.Tenancy
-	request.CidrBlock = cidrBlock
-	request.DisplayName = displayName
-	request.DnsLabel = dnsLabel
-	request.RequestMetadata = getCustomRequestMetadataWithRetryPolicy()
+	return strings.Join(items, ", ")
+}
 
-	request.VcnId = vcnID
-	var r core.CreateSubnetResponse
-	r, err = c.CreateSubnet(ctx, request)
-	if err != nil {
+// 读取防火墙规则组。名称为空时使用 [FIREWALL], 否则使用 [FIREWALL.名称] (包含 [FIREWALL] 中的规则, 同名规则设置为空可以删除)
+func loadFirewallRules(name string) (rules []firewallRule, err error) {
+	sec := firewallBaseSection
+	if sec == nil {
 		return
 	}
-	// retry condition check, stop unitl return true
-	pollUntilAvailable := func(r common.OCIOperationResponse) bool {
-		if converted, ok := r.Response.(core.GetSubnetResponse); ok {
-			return converted.LifecycleState != core.SubnetLifecycleStateAvailable
+	if name != "" {
+		sec = nil
+		for _, child := range firewallBaseSection.ChildSections() {
+			if child.Name() == firewallBaseSection.Name()+"."+name {
+				sec = child
+				break
+			}
+		}
+		if sec == nil {
+			return nil, fmt.Errorf("未找到防火墙规则组 [%s.%s]", firewallBaseSection.Name(), name)
+		}
+	}
+	var names []string
+	values := make(map[string]string)
+	for _, key := range append(sec.ParentKeys(), sec.Keys()...) {
+		if _, ok := values[key.Name()]; !ok {
+			names = append(names, key.Name())
 		}
-		return true
+		values[key.Name()] = key.Value()
 	}
-
-	pollGetRequest := core.GetSubnetRequest{
-		SubnetId:        r.Id,
-		RequestMetadata: helpers.GetRequestMetadataWithCustomizedRetryPolicy(pollUntilAvailable),
+	for _, n := range names {
+		if strings.TrimSpace(values[n]) == "" {
+			continue
+		}
+		var rule firewallRule
+		rule, err = parseFirewallRule(n, values[n])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
 	}
+	return
+}
 
-	// wait for lifecyle become running
-	_, err = c.GetSubnet(ctx, pollGetRequest)
-	if err != nil {
-		return
+// 设置新建子网的安全列表入站规则: 配置了防火墙规则时替换默认规则, 否则允许所有协议
+func applySubnetSecurityList(ctx context.Context, c core.VirtualNetworkClient, securityListId *string, layout networkLayout) error {
+	if layout.UseNsg {
+		// 防火墙规则添加到网络安全组, 安全列表保持默认规则
+		return nil
+	}
+	rules := []core.IngressSecurityRule{}
+	if len(layout.Firewall) == 0 {
+		getResp, err := c.GetSecurityList(ctx, core.GetSecurityListRequest{
+			SecurityListId:  securityListId,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			return err
+		}
+		rules = append(getResp.IngressSecurityRules, core.IngressSecurityRule{
+			Protocol: common.String("all"), // 允许所有协议
+			Source:   common.String("0.0.0.0/0"),
+		})
+	} else {
+		for _, r := range layout.Firewall {
+			rules = append(rules, r.ingressSecurityRule())
+		}
 	}
+	return updateSecurityListIngress(ctx, c, securityListId, rules)
+}
 
-	// update the security rules
-	getReq := core.GetSecurityListRequest{
-		SecurityListId:  common.String(r.SecurityListIds[0]),
+// 替换安全列表的入站规则
+func updateSecurityListIngress(ctx context.Context, c core.VirtualNetworkClient, securityListId *string, rules []core.IngressSecurityRule) error {
+	updateReq := core.UpdateSecurityListRequest{
+		SecurityListId:  securityListId,
 		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
 	}
+	updateReq.IngressSecurityRules = rules
+	_, err := c.UpdateSecurityList(ctx, updateReq)
+	return err
+}
 
-	var getResp core.GetSecurityListResponse
-	getResp, err = c.GetSecurityList(ctx, getReq)
-	if err != nil {
-		return
+// 新建子网时安全列表的说明, 用于显示 dry run 计划
+func (layout networkLayout) securityListPlan() string {
+	if layout.UseNsg {
+		return "安全列表: 使用默认规则"
 	}
+	if len(layout.Firewall) == 0 {
+		return "添加安全规则: 允许所有协议 0.0.0.0/0"
+	}
+	return "设置安全规则: " + firewallRulesDesc(layout.Firewall)
+}
 
-	// this security rule allows remote control the instance
-	/*portRange := core.PortRange{
-		Max: common.Int(1521),
-		Min: common.Int(1521),
-	}*/
+// 列出 VCN 中的网络安全组
+func listNetworkSecurityGroups(ctx context.Context, c core.VirtualNetworkClient, vcnID *string) (nsgs []core.NetworkSecurityGroup, err error) {
+	var page *string
+	for {
+		var resp core.ListNetworkSecurityGroupsResponse
+		resp, err = c.ListNetworkSecurityGroups(ctx, core.ListNetworkSecurityGroupsRequest{
+			CompartmentId:   &oracle.Tenancy,
+			VcnId:           vcnID,
+			Page:            page,
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			return
+		}
+		for _, nsg := range resp.Items {
+			if nsg.LifecycleState != core.NetworkSecurityGroupLifecycleStateTerminating && nsg.LifecycleState != core.NetworkSecurityGroupLifecycleStateTerminated {
+				nsgs = append(nsgs, nsg)
+			}
+		}
+		page = resp.OpcNextPage
+		if page == nil {
+			break
+		}
+	}
+	return
+}
 
-	newRules := append(getResp.IngressSecurityRules, core.IngressSecurityRule{
-		//Protocol: common.String("6"), // TCP
-		Protocol: common.String("all"), // 允许所有协议
-		Source:   common.String("0.0.0.0/0"),
-		/*TcpOptions: &core.TcpOptions{
-			DestinationPortRange: &portRange, // 省略该参数，允许所有目标端口。
-		},*/
-	})
+// 列出网络安全组的入站规则
+func listNsgIngressRules(ctx context.Context, c core.VirtualNetworkClient, nsgID *string) (rules []core.SecurityRule, err error) {
+	var page *string
+	for {
+		var resp core.ListNetworkSecurityGroupSecurityRulesResponse
+		resp, err = c.ListNetworkSecurityGroupSecurityRules(ctx, core.ListNetworkSecurityGroupSecurityRulesRequest{
+			NetworkSecurityGroupId: nsgID,
+			Direction:              core.ListNetworkSecurityGroupSecurityRulesDirectionIngress,
+			Page:                   page,
+			RequestMetadata:        getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			return
+		}
+		rules = append(rules, resp.Items...)
+		page = resp.OpcNextPage
+		if page == nil {
+			break
+		}
+	}
+	return
+}
 
-	updateReq := core.UpdateSecurityListRequest{
-		SecurityListId:  common.String(r.SecurityListIds[0]),
-		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+// 添加网络安全组入站规则
+func addNsgRules(ctx context.Context, c core.VirtualNetworkClient, nsgID *string, rules []firewallRule) error {
+	var details []core.AddSecurityRuleDetails
+	for _, r := range rules {
+		details = append(details, r.addSecurityRuleDetails())
+	}
+	// 每次最多添加 25 条规则
+	for len(details) > 0 {
+		n := len(details)
+		if n > 25 {
+			n = 25
+		}
+		_, err := c.AddNetworkSecurityGroupSecurityRules(ctx, core.AddNetworkSecurityGroupSecurityRulesRequest{
+			NetworkSecurityGroupId: nsgID,
+			AddNetworkSecurityGroupSecurityRulesDetails: core.AddNetworkSecurityGroupSecurityRulesDetails{
+				SecurityRules: details[:n],
+			},
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			return err
+		}
+		details = details[n:]
 	}
+	return nil
+}
 
-	updateReq.IngressSecurityRules = newRules
+// 删除网络安全组规则
+func removeNsgRules(ctx context.Context, c core.VirtualNetworkClient, nsgID *string, ruleIds []string) error {
+	if len(ruleIds) == 0 {
+		return nil
+	}
+	_, err := c.RemoveNetworkSecurityGroupSecurityRules(ctx, core.RemoveNetworkSecurityGroupSecurityRulesRequest{
+		NetworkSecurityGroupId: nsgID,
+		RemoveNetworkSecurityGroupSecurityRulesDetails: core.RemoveNetworkSecurityGroupSecurityRulesDetails{
+			SecurityRuleIds: ruleIds,
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
+	return err
+}
 
-	_, err = c.UpdateSecurityList(ctx, updateReq)
+// 使网络安全组的入站规则与防火墙规则一致: 删除多余的规则, 添加缺少的规则
+func syncNsgRules(ctx context.Context, c core.VirtualNetworkClient, nsgID *string, rules []firewallRule) error {
+	existing, err := listNsgIngressRules(ctx, c, nsgID)
 	if err != nil {
-		return
+		return err
 	}
-	fmt.Printf("Subnet创建成功: %s\n", *r.Subnet.DisplayName)
-	subnet = r.Subnet
-	return
+	removeIds, add := diffNsgRules(existing, rules)
+	err = removeNsgRules(ctx, c, nsgID, removeIds)
+	if err != nil {
+		return err
+	}
+	return addNsgRules(ctx, c, nsgID, add)
 }
 
-// 列出指定虚拟云网络 (VCN) 中的所有子网
-func listSubnets(ctx context.Context, c core.VirtualNetworkClient, vcnID *string) (subnets []core.Subnet, err error) {
-	request := core.ListSubnetsRequest{
-		CompartmentId:   &oracle.Tenancy,
-		VcnId:           vcnID,
-		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+// 比较网络安全组已有的入站规则和防火墙规则, 返回要删除的规则 ID (多余或重复的规则) 和要添加的规则
+func diffNsgRules(existing []core.SecurityRule, rules []firewallRule) (removeIds []string, add []firewallRule) {
+	desired := make(map[string]bool)
+	for _, r := range rules {
+		desired[r.key()] = true
+	}
+	present := make(map[string]bool)
+	for _, r := range existing {
+		key := firewallRuleFromSecurityRule(r).key()
+		if desired[key] && !present[key] {
+			present[key] = true
+		} else {
+			removeIds = append(removeIds, *r.Id)
+		}
 	}
-	var r core.ListSubnetsResponse
-	r, err = c.ListSubnets(ctx, request)
-	if err != nil {
-		return
+	for _, r := range rules {
+		if !present[r.key()] {
+			present[r.key()] = true
+			add = append(add, r)
+		}
 	}
-	subnets = r.Items
 	return
 }
 
-// 创建一个新的虚拟云网络 (VCN) 或获取已经存在的虚拟云网络
-func createOrGetVcn(ctx context.Context, c core.VirtualNetworkClient) (core.Vcn, error) {
-	var vcn core.Vcn
-	vcnItems, err := listVcns(ctx, c)
+// 创建实例时附加的网络安全组: firewallTarget=nsg 时按防火墙规则创建或更新的网络安全组, 以及 nsgIds 中指定的网络安全组
+func getLaunchNsgIds(ctx context.Context, c core.VirtualNetworkClient, subnet core.Subnet) (nsgIds []string, err error) {
+	var layout networkLayout
+	layout, err = getNetworkLayout()
 	if err != nil {
-		return vcn, err
-	}
-	displayName := common.String(instance.VcnDisplayName)
-	if len(vcnItems) > 0 && *displayName == "" {
-		vcn = vcnItems[0]
-		return vcn, err
+		return
 	}
-	for _, element := range vcnItems {
-		if *element.DisplayName == instance.VcnDisplayName {
-			// VCN already created, return it
-			vcn = element
-			return vcn, err
+	var names []string
+	for _, v := range strings.Split(layout.NsgIds, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			names = append(names, v)
 		}
 	}
-	// create a new VCN
-	fmt.Println("开始创建VCN（没有可用的VCN，或指定的VCN不存在）")
-	if *displayName == "" {
-		displayName = common.String(time.Now().Format("vcn-20060102-1504"))
+	if subnet.VcnId == nil {
+		// dry run 模式下 VCN 尚未创建
+		if layout.UseNsg {
+			networkPlan = append(networkPlan, fmt.Sprintf("创建网络安全组: %s (%s)", layout.NsgName, firewallRulesDesc(layout.Firewall)))
+		}
+		if len(names) > 0 {
+			err = fmt.Errorf("VCN 尚未创建, 未找到网络安全组 %s", strings.Join(names, ","))
+		}
+		return
 	}
-	request := core.CreateVcnRequest{}
-	request.RequestMetadata = getCustomRequestMetadataWithRetryPolicy()
-	request.CidrBlock = common.String("10.0.0.0/16")
-	request.CompartmentId = common.String(oracle.Tenancy)
-	request.DisplayName = displayName
-	request.DnsLabel = common.String("vcndns")
-	r, err := c.CreateVcn(ctx, request)
+	var nsgs []core.NetworkSecurityGroup
+	nsgs, err = listNetworkSecurityGroups(ctx, c, subnet.VcnId)
 	if err != nil {
-		return vcn, err
+		return
 	}
-	fmt.Printf("VCN创建成功: %s\n", *r.Vcn.DisplayName)
-	vcn = r.Vcn
-	return vcn, err
-}
-
-// 列出所有虚拟云网络 (VCN)
-func listVcns(ctx context.Context, c core.VirtualNetworkClient) ([]core.Vcn, error) {
-	request := core.ListVcnsRequest{
-		CompartmentId:   &oracle.Tenancy,
-		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	if layout.UseNsg {
+		var nsg *core.NetworkSecurityGroup
+		for i := range nsgs {
+			if *nsgs[i].DisplayName == layout.NsgName {
+				nsg = &nsgs[i]
+				break
+			}
+		}
+		if isDryRun() {
+			if nsg == nil {
+				networkPlan = append(networkPlan, fmt.Sprintf("创建网络安全组: %s (%s)", layout.NsgName, firewallRulesDesc(layout.Firewall)))
+			} else {
+				networkPlan = append(networkPlan, fmt.Sprintf("更新网络安全组规则: %s (%s)", layout.NsgName, firewallRulesDesc(layout.Firewall)))
+				nsgIds = append(nsgIds, *nsg.Id)
+			}
+		} else {
+			if nsg == nil {
+				fmt.Printf("开始创建网络安全组: %s\n", layout.NsgName)
+				var resp core.CreateNetworkSecurityGroupResponse
+				resp, err = c.CreateNetworkSecurityGroup(ctx, core.CreateNetworkSecurityGroupRequest{
+					CreateNetworkSecurityGroupDetails: core.CreateNetworkSecurityGroupDetails{
+						CompartmentId: &oracle.Tenancy,
+						VcnId:         subnet.VcnId,
+						DisplayName:   common.String(layout.NsgName),
+					},
+					RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+				})
+				if err != nil {
+					return
+				}
+				nsg = &resp.NetworkSecurityGroup
+			}
+			err = syncNsgRules(ctx, c, nsg.Id, layout.Firewall)
+			if err != nil {
+				return
+			}
+			nsgIds = append(nsgIds, *nsg.Id)
+		}
 	}
-	r, err := c.ListVcns(ctx, request)
-	if err != nil {
-		return nil, err
+	for _, name := range names {
+		if strings.HasPrefix(name, "ocid1.") {
+			nsgIds = append(nsgIds, name)
+			continue
+		}
+		found := false
+		for _, nsg := range nsgs {
+			if *nsg.DisplayName == name {
+				nsgIds = append(nsgIds, *nsg.Id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = fmt.Errorf("未在 VCN 中找到网络安全组 %s", name)
+			return
+		}
 	}
-	return r.Items, err
+	// 每个 VNIC 最多附加 5 个网络安全组
+	if len(nsgIds) > 5 {
+		err = fmt.Errorf("每个 VNIC 最多附加 5 个网络安全组, 当前 %d 个", len(nsgIds))
+	}
+	return
 }
 
-// 创建或者获取 Internet 网关
-func createOrGetInternetGateway(c core.VirtualNetworkClient, vcnID *string) (core.InternetGateway, error) {
-	//List Gateways
-	var gateway core.InternetGateway
-	listGWRequest := core.ListInternetGatewaysRequest{
-		CompartmentId:   &oracle.Tenancy,
-		VcnId:           vcnID,
-		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+// 获取系统镜像
+// 1. 设置了 imageId, 使用指定的镜像。
+// 2. 设置了 imagePin, 使用上次创建成功时使用的镜像。
+// 3. 否则在符合条件的镜像中选择最新的一个。
+func GetImage(ctx context.Context, c core.ComputeClient) (image core.Image, err error) {
+	imageId := instance.ImageId
+	if imageId == "" && instance.ImagePin {
+		imageId = loadImagePin()
+	}
+	if imageId != "" {
+		var resp core.GetImageResponse
+		resp, err = c.GetImage(ctx, core.GetImageRequest{
+			ImageId:         common.String(imageId),
+			RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err == nil || instance.ImageId != "" {
+			return resp.Image, err
+		}
+		printlnErr("获取上次使用的系统镜像失败, 重新选择镜像", err.Error())
 	}
 
-	listGWRespone, err := c.ListInternetGateways(ctx, listGWRequest)
+	var images []core.Image
+	images, err = listImages(ctx, c)
 	if err != nil {
-		fmt.Printf("Internet gateway list error: %s\n", err.Error())
-		return gateway, err
+		return
 	}
-
-	if len(listGWRespone.Items) >= 1 {
-		//Gateway with name already exists
-		gateway = listGWRespone.Items[0]
+	if len(images) > 0 {
+		image = images[0]
 	} else {
-		//Create new Gateway
-		fmt.Printf("开始创建Internet网关\n")
-		enabled := true
-		createGWDetails := core.CreateInternetGatewayDetails{
-			CompartmentId: &oracle.Tenancy,
-			IsEnabled:     &enabled,
-			VcnId:         vcnID,
-		}
-
-		createGWRequest := core.CreateInternetGatewayRequest{
-			CreateInternetGatewayDetails: createGWDetails,
-			RequestMetadata:              getCustomRequestMetadataWithRetryPolicy()}
-
-		createGWResponse, err := c.CreateInternetGateway(ctx, createGWRequest)
+		err = fmt.Errorf("未找到[%s %s %s]的镜像, 或该镜像不支持[%s]", instance.OperatingSystem, instance.OperatingSystemVersion, instance.ImageName, instance.Shape)
+	}
+	return
+}
 
+// 列出所有符合条件的系统镜像, 按创建时间从新到旧排序
+// imageName: 镜像名称 (正则表达式), imageSource: platform 平台镜像 / custom 自定义镜像 / 空表示全部
+func listImages(ctx context.Context, c core.ComputeClient) ([]core.Image, error) {
+	custom := strings.EqualFold(instance.ImageSource, "custom")
+	if !custom && instance.ImageName == "" && (instance.OperatingSystem == "" || instance.OperatingSystemVersion == "") {
+		return nil, errors.New("操作系统类型和版本不能为空, 请检查配置文件")
+	}
+	var nameRegexp *regexp.Regexp
+	if instance.ImageName != "" {
+		var err error
+		nameRegexp, err = regexp.Compile(instance.ImageName)
 		if err != nil {
-			fmt.Printf("Internet gateway create error: %s\n", err.Error())
-			return gateway, err
+			return nil, err
 		}
-		gateway = createGWResponse.InternetGateway
-		fmt.Printf("Internet网关创建成功: %s\n", *gateway.DisplayName)
 	}
-	return gateway, err
-}
-
-// 创建或者获取路由表
-func createOrGetRouteTable(c core.VirtualNetworkClient, gatewayID, VcnID *string) (routeTable core.RouteTable, err error) {
-	//List Route Table
-	listRTRequest := core.ListRouteTablesRequest{
-		CompartmentId:   &oracle.Tenancy,
-		VcnId:           VcnID,
+	request := core.ListImagesRequest{
+		CompartmentId:   common.String(oracle.Tenancy),
+		Shape:           common.String(instance.Shape),
+		SortBy:          core.ListImagesSortByTimecreated,
+		SortOrder:       core.ListImagesSortOrderDesc,
 		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
 	}
-	var listRTResponse core.ListRouteTablesResponse
-	listRTResponse, err = c.ListRouteTables(ctx, listRTRequest)
-	if err != nil {
-		fmt.Printf("Route table list error: %s\n", err.Error())
-		return
+	if instance.OperatingSystem != "" {
+		request.OperatingSystem = common.String(instance.OperatingSystem)
 	}
-
-	cidrRange := "0.0.0.0/0"
-	rr := core.RouteRule{
-		NetworkEntityId: gatewayID,
-		Destination:     &cidrRange,
-		DestinationType: core.RouteRuleDestinationTypeCidrBlock,
+	if instance.OperatingSystemVersion != "" {
+		request.OperatingSystemVersion = common.String(instance.OperatingSystemVersion)
 	}
-
-	if len(listRTResponse.Items) >= 1 {
-		//Default Route Table found and has at least 1 route rule
-		if len(listRTResponse.Items[0].RouteRules) >= 1 {
-			routeTable = listRTResponse.Items[0]
-			//Default Route table needs route rule adding
-		} else {
-			fmt.Printf("路由表未添加规则，开始添加Internet路由规则\n")
-			updateRTDetails := core.UpdateRouteTableDetails{
-				RouteRules: []core.RouteRule{rr},
-			}
-
-			updateRTRequest := core.UpdateRouteTableRequest{
-				RtId:                    listRTResponse.Items[0].Id,
-				UpdateRouteTableDetails: updateRTDetails,
-				RequestMetadata:         getCustomRequestMetadataWithRetryPolicy(),
+	var images []core.Image
+	for {
+		r, err := c.ListImages(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range r.Items {
+			// 平台镜像没有 CompartmentId
+			isCustom := image.CompartmentId != nil
+			if custom && !isCustom || strings.EqualFold(instance.ImageSource, "platform") && isCustom {
+				continue
 			}
-			var updateRTResponse core.UpdateRouteTableResponse
-			updateRTResponse, err = c.UpdateRouteTable(ctx, updateRTRequest)
-			if err != nil {
-				fmt.Printf("Error updating route table: %s\n", err)
-				return
+			if nameRegexp != nil && !nameRegexp.MatchString(*image.DisplayName) {
+				continue
 			}
-			fmt.Printf("Internet路由规则添加成功\n")
-			routeTable = updateRTResponse.RouteTable
-		}
-
-	} else {
-		//No default route table found
-		fmt.Printf("Error could not find VCN default route table, VCN OCID: %s Could not find route table.\n", *VcnID)
+			images = append(images, image)
+		}
+		if r.OpcNextPage == nil || len(r.Items) == 0 {
+			break
+		}
+		request.Page = r.OpcNextPage
 	}
-	return
+	return images, nil
 }
 
-// 获取符合条件系统镜像中的第一个
-func GetImage(ctx context.Context, c core.ComputeClient) (image core.Image, err error) {
-	var images []core.Image
-	images, err = listImages(ctx, c)
+// 读取当前实例模版上次创建成功时使用的系统镜像
+func loadImagePin() string {
+	pins := make(map[string]string)
+	data, err := ioutil.ReadFile(imagePinFilePath)
 	if err != nil {
-		return
+		return ""
 	}
-	if len(images) > 0 {
-		image = images[0]
-	} else {
-		err = fmt.Errorf("未找到[%s %s]的镜像, 或该镜像不支持[%s]", instance.OperatingSystem, instance.OperatingSystemVersion, instance.Shape)
-	}
-	return
+	json.Unmarshal(data, &pins)
+	return pins[oracleSectionName+"/"+instanceSectionName]
 }
 
-// 列出所有符合条件的系统镜像
-func listImages(ctx context.Context, c core.ComputeClient) ([]core.Image, error) {
-	if instance.OperatingSystem == "" || instance.OperatingSystemVersion == "" {
-		return nil, errors.New("操作系统类型和版本不能为空, 请检查配置文件")
+// 记录当前实例模版创建成功时使用的系统镜像
+func saveImagePin(image core.Image) error {
+	pins := make(map[string]string)
+	if data, err := ioutil.ReadFile(imagePinFilePath); err == nil {
+		json.Unmarshal(data, &pins)
 	}
-	request := core.ListImagesRequest{
-		CompartmentId:          common.String(oracle.Tenancy),
-		OperatingSystem:        common.String(instance.OperatingSystem),
-		OperatingSystemVersion: common.String(instance.OperatingSystemVersion),
-		Shape:                  common.String(instance.Shape),
-		RequestMetadata:        getCustomRequestMetadataWithRetryPolicy(),
+	pins[oracleSectionName+"/"+instanceSectionName] = *image.Id
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return err
 	}
-	r, err := c.ListImages(ctx, request)
-	return r.Items, err
+	return ioutil.WriteFile(imagePinFilePath, data, 0644)
 }
 
 func getShape(imageId *string, shapeName string) (core.Shape, error) {
@@ -2092,41 +7404,71 @@ func instanceAction(instanceId *string, action core.InstanceActionActionEnum) (i
 	return
 }
 
-func changePublicIp(vnics []core.Vnic) (publicIp core.PublicIp, err error) {
+// 更换实例主网卡的公共IP, reservedIpId 为空时创建新的临时公共IP, 否则分配指定的预留公共IP
+func changePublicIp(vnics []core.Vnic, reservedIpId *string) (publicIp core.PublicIp, err error) {
+	fmt.Println("正在获取私有IP...")
+	privateIp, err := getPrimaryPrivateIp(vnics)
+	if err != nil {
+		printlnErr("获取私有IP失败", err.Error())
+		return
+	}
+
+	err = releasePublicIp(privateIp.Id)
+	if err != nil {
+		printlnErr("删除公共IP 失败", err.Error())
+		return
+	}
+	time.Sleep(3 * time.Second)
+	if reservedIpId != nil {
+		fmt.Println("正在分配预留公共IP...")
+		publicIp, err = updatePublicIp(reservedIpId, privateIp.Id)
+		return
+	}
+	fmt.Println("正在创建公共IP...")
+	publicIp, err = createPublicIp(privateIp.Id)
+	return
+}
+
+// 获取主网卡的主私有IP
+func getPrimaryPrivateIp(vnics []core.Vnic) (privateIp core.PrivateIp, err error) {
 	var vnic core.Vnic
 	for _, v := range vnics {
 		if *v.IsPrimary {
 			vnic = v
 		}
 	}
-	fmt.Println("正在获取私有IP...")
 	var privateIps []core.PrivateIp
 	privateIps, err = getPrivateIps(vnic.Id)
 	if err != nil {
-		printlnErr("获取私有IP失败", err.Error())
 		return
 	}
-	var privateIp core.PrivateIp
 	for _, p := range privateIps {
 		if *p.IsPrimary {
 			privateIp = p
 		}
 	}
+	return
+}
 
+// 释放私有IP的公共IP: 删除临时公共IP, 预留公共IP取消分配 (保留在账号中)
+func releasePublicIp(privateIpId *string) error {
 	fmt.Println("正在获取公共IP OCID...")
-	publicIp, err = getPublicIp(privateIp.Id)
+	publicIp, err := getPublicIp(privateIpId)
+	if servErr, ok := common.IsServiceError(err); ok && servErr.GetHTTPStatusCode() == 404 || errors.Is(err, errNoPublicIp) {
+		// 没有分配公共IP
+		return nil
+	}
 	if err != nil {
-		printlnErr("获取公共IP OCID 失败", err.Error())
+		return err
+	}
+	if publicIp.Lifetime == core.PublicIpLifetimeReserved {
+		fmt.Println("正在取消分配预留公共IP...")
+		_, err = updatePublicIp(publicIp.Id, common.String(""))
+		return err
 	}
 	fmt.Println("正在删除公共IP...")
 	_, err = deletePublicIp(publicIp.Id)
-	if err != nil {
-		printlnErr("删除公共IP 失败", err.Error())
-	}
-	time.Sleep(3 * time.Second)
-	fmt.Println("正在创建公共IP...")
-	publicIp, err = createPublicIp(privateIp.Id)
-	return
+	return err
 }
 
 func getInstanceVnics(instanceId *string) (vnics []core.Vnic, err error) {
@@ -2177,11 +7519,45 @@ func getPublicIp(privateIpId *string) (core.PublicIp, error) {
 	}
 	resp, err := networkClient.GetPublicIpByPrivateIpId(ctx, req)
 	if err == nil && resp.PublicIp.Id == nil {
-		err = errors.New("未分配公共IP")
+		err = errNoPublicIp
 	}
 	return resp.PublicIp, err
 }
 
+// 列出公共IP, 预留公共IP的范围为 REGION, 临时公共IP的范围为 AVAILABILITY_DOMAIN
+func listPublicIps(scope core.ListPublicIpsScopeEnum, availabilityDomain *string, lifetime core.ListPublicIpsLifetimeEnum) (ips []core.PublicIp, err error) {
+	req := core.ListPublicIpsRequest{
+		Scope:              scope,
+		CompartmentId:      common.String(oracle.Tenancy),
+		AvailabilityDomain: availabilityDomain,
+		Lifetime:           lifetime,
+		RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
+	}
+	for {
+		var resp core.ListPublicIpsResponse
+		resp, err = networkClient.ListPublicIps(ctx, req)
+		if err != nil {
+			return
+		}
+		ips = append(ips, resp.Items...)
+		if resp.OpcNextPage == nil || len(resp.Items) == 0 {
+			break
+		}
+		req.Page = resp.OpcNextPage
+	}
+	return
+}
+
+// 列出VNIC的IPv6地址
+func getIpv6s(vnicId *string) ([]core.Ipv6, error) {
+	req := core.ListIpv6sRequest{
+		VnicId:          vnicId,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := networkClient.ListIpv6s(ctx, req)
+	return resp.Items, err
+}
+
 // 删除公共IP
 // 取消分配并删除指定公共IP（临时或保留）
 // 如果仅需要取消分配保留的公共IP并将保留的公共IP返回到保留公共IP池，请使用updatePublicIp方法。
@@ -2211,6 +7587,44 @@ func createPublicIp(privateIpId *string) (core.PublicIp, error) {
 	return publicIp, err
 }
 
+// 预留一个新的公共IP
+func createReservedPublicIp(displayName string) (core.PublicIp, error) {
+	req := core.CreatePublicIpRequest{
+		CreatePublicIpDetails: core.CreatePublicIpDetails{
+			CompartmentId: common.String(oracle.Tenancy),
+			DisplayName:   common.String(displayName),
+			Lifetime:      core.CreatePublicIpDetailsLifetimeReserved,
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := networkClient.CreatePublicIp(ctx, req)
+	return resp.PublicIp, err
+}
+
+// 获取公共IP所分配的实例
+func getPublicIpInstance(publicIp core.PublicIp) (ins core.Instance, err error) {
+	privateIpResp, err := networkClient.GetPrivateIp(ctx, core.GetPrivateIpRequest{
+		PrivateIpId:     publicIp.AssignedEntityId,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err != nil {
+		return
+	}
+	attachmentsResp, err := computeClient.ListVnicAttachments(ctx, core.ListVnicAttachmentsRequest{
+		CompartmentId:   common.String(oracle.Tenancy),
+		VnicId:          privateIpResp.PrivateIp.VnicId,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err != nil {
+		return
+	}
+	if len(attachmentsResp.Items) == 0 {
+		err = errors.New("未找到实例")
+		return
+	}
+	return getInstance(attachmentsResp.Items[0].InstanceId)
+}
+
 // 更新保留公共IP
 // 1. 将保留的公共IP分配给指定的私有IP。如果该公共IP已经分配给私有IP，会取消分配，然后重新分配给指定的私有IP。
 // 2. PrivateIpId设置为空字符串，公共IP取消分配到关联的私有IP。
@@ -2268,15 +7682,69 @@ func getInstancePublicIps(instanceId *string) (ips []string, err error) {
 	return
 }
 
+// 获取实例的串行控制台历史记录 (最后 1MB)
+func getConsoleHistory(client core.ComputeClient, instanceId *string) (content string, err error) {
+	captureResp, err := client.CaptureConsoleHistory(ctx, core.CaptureConsoleHistoryRequest{
+		CaptureConsoleHistoryDetails: core.CaptureConsoleHistoryDetails{InstanceId: instanceId},
+		RequestMetadata:              getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err != nil {
+		return
+	}
+	historyId := captureResp.ConsoleHistory.Id
+	defer client.DeleteConsoleHistory(ctx, core.DeleteConsoleHistoryRequest{InstanceConsoleHistoryId: historyId})
+	for i := 0; i < 20; i++ {
+		var resp core.GetConsoleHistoryResponse
+		resp, err = client.GetConsoleHistory(ctx, core.GetConsoleHistoryRequest{
+			InstanceConsoleHistoryId: historyId,
+			RequestMetadata:          getCustomRequestMetadataWithRetryPolicy(),
+		})
+		if err != nil {
+			return
+		}
+		if resp.ConsoleHistory.LifecycleState == core.ConsoleHistoryLifecycleStateFailed {
+			err = errors.New("获取控制台历史记录失败")
+			return
+		}
+		if resp.ConsoleHistory.LifecycleState == core.ConsoleHistoryLifecycleStateSucceeded {
+			break
+		}
+		time.Sleep(3 * time.Second)
+	}
+	contentResp, err := client.GetConsoleHistoryContent(ctx, core.GetConsoleHistoryContentRequest{
+		InstanceConsoleHistoryId: historyId,
+		Length:                   common.Int(1024 * 1024),
+		RequestMetadata:          getCustomRequestMetadataWithRetryPolicy(),
+	})
+	if err != nil {
+		return
+	}
+	if contentResp.Value != nil {
+		content = *contentResp.Value
+	}
+	return
+}
+
 // 列出引导卷
-func getBootVolumes(availabilityDomain *string) ([]core.BootVolume, error) {
+func getBootVolumes(availabilityDomain *string) (volumes []core.BootVolume, err error) {
 	req := core.ListBootVolumesRequest{
 		AvailabilityDomain: availabilityDomain,
 		CompartmentId:      common.String(oracle.Tenancy),
 		RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
 	}
-	resp, err := storageClient.ListBootVolumes(ctx, req)
-	return resp.Items, err
+	for {
+		var resp core.ListBootVolumesResponse
+		resp, err = storageClient.ListBootVolumes(ctx, req)
+		if err != nil {
+			return
+		}
+		volumes = append(volumes, resp.Items...)
+		if resp.OpcNextPage == nil {
+			break
+		}
+		req.Page = resp.OpcNextPage
+	}
+	return
 }
 
 // 获取指定引导卷
@@ -2339,6 +7807,214 @@ func listBootVolumeAttachments(availabilityDomain, compartmentId, bootVolumeId *
 	return resp.Items, err
 }
 
+// 列出当前账号的自定义镜像
+func getCustomImages() (images []core.Image, err error) {
+	req := core.ListImagesRequest{
+		CompartmentId:   common.String(oracle.Tenancy),
+		SortBy:          core.ListImagesSortByTimecreated,
+		SortOrder:       core.ListImagesSortOrderDesc,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	for {
+		var resp core.ListImagesResponse
+		resp, err = computeClient.ListImages(ctx, req)
+		if err != nil {
+			return
+		}
+		for _, image := range resp.Items {
+			// 平台镜像没有 CompartmentId
+			if image.CompartmentId != nil && image.LifecycleState != core.ImageLifecycleStateDeleted {
+				images = append(images, image)
+			}
+		}
+		if resp.OpcNextPage == nil || len(resp.Items) == 0 {
+			break
+		}
+		req.Page = resp.OpcNextPage
+	}
+	return
+}
+
+// 从实例创建自定义镜像
+// https://docs.oracle.com/en-us/iaas/api/#/en/iaas/20160918/Image/CreateImage
+func createImage(instanceId *string, displayName string) (core.Image, error) {
+	req := core.CreateImageRequest{
+		CreateImageDetails: core.CreateImageDetails{
+			CompartmentId: common.String(oracle.Tenancy),
+			DisplayName:   common.String(displayName),
+			InstanceId:    instanceId,
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeClient.CreateImage(ctx, req)
+	return resp.Image, err
+}
+
+// 从对象存储URL导入自定义镜像
+func importImage(displayName, sourceUri string) (core.Image, error) {
+	req := core.CreateImageRequest{
+		CreateImageDetails: core.CreateImageDetails{
+			CompartmentId: common.String(oracle.Tenancy),
+			DisplayName:   common.String(displayName),
+			ImageSourceDetails: core.ImageSourceViaObjectStorageUriDetails{
+				SourceUri: common.String(sourceUri),
+			},
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeClient.CreateImage(ctx, req)
+	return resp.Image, err
+}
+
+// 导出自定义镜像到对象存储URL
+func exportImage(imageId *string, destinationUri string) (core.Image, error) {
+	req := core.ExportImageRequest{
+		ImageId: imageId,
+		ExportImageDetails: core.ExportImageViaObjectStorageUriDetails{
+			DestinationUri: common.String(destinationUri),
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeClient.ExportImage(ctx, req)
+	return resp.Image, err
+}
+
+// 修改自定义镜像名称
+func updateImageName(imageId *string, displayName string) (core.Image, error) {
+	req := core.UpdateImageRequest{
+		ImageId:            imageId,
+		UpdateImageDetails: core.UpdateImageDetails{DisplayName: common.String(displayName)},
+		RequestMetadata:    getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeClient.UpdateImage(ctx, req)
+	return resp.Image, err
+}
+
+// 删除自定义镜像
+func deleteImage(imageId *string) error {
+	req := core.DeleteImageRequest{
+		ImageId:         imageId,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	_, err := computeClient.DeleteImage(ctx, req)
+	return err
+}
+
+// 列出实例池
+func getInstancePools() (pools []core.InstancePoolSummary, err error) {
+	req := core.ListInstancePoolsRequest{
+		CompartmentId:   common.String(oracle.Tenancy),
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	for {
+		var resp core.ListInstancePoolsResponse
+		resp, err = computeMgmtClient.ListInstancePools(ctx, req)
+		if err != nil {
+			return
+		}
+		for _, pool := range resp.Items {
+			if pool.LifecycleState != core.InstancePoolSummaryLifecycleStateTerminated {
+				pools = append(pools, pool)
+			}
+		}
+		if resp.OpcNextPage == nil || len(resp.Items) == 0 {
+			break
+		}
+		req.Page = resp.OpcNextPage
+	}
+	return
+}
+
+// 列出实例池中的实例
+func listInstancePoolInstances(poolId *string) (instances []core.InstanceSummary, err error) {
+	req := core.ListInstancePoolInstancesRequest{
+		CompartmentId:   common.String(oracle.Tenancy),
+		InstancePoolId:  poolId,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	for {
+		var resp core.ListInstancePoolInstancesResponse
+		resp, err = computeMgmtClient.ListInstancePoolInstances(ctx, req)
+		if err != nil {
+			return
+		}
+		instances = append(instances, resp.Items...)
+		if resp.OpcNextPage == nil || len(resp.Items) == 0 {
+			break
+		}
+		req.Page = resp.OpcNextPage
+	}
+	return
+}
+
+// 创建实例配置
+// https://docs.oracle.com/en-us/iaas/api/#/en/iaas/20160918/InstanceConfiguration/CreateInstanceConfiguration
+func createInstanceConfiguration(details core.CreateInstanceConfigurationDetails) (core.InstanceConfiguration, error) {
+	req := core.CreateInstanceConfigurationRequest{
+		CreateInstanceConfiguration: details,
+		RequestMetadata:             getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeMgmtClient.CreateInstanceConfiguration(ctx, req)
+	return resp.InstanceConfiguration, err
+}
+
+// 删除实例配置
+func deleteInstanceConfiguration(configId *string) error {
+	req := core.DeleteInstanceConfigurationRequest{
+		InstanceConfigurationId: configId,
+		RequestMetadata:         getCustomRequestMetadataWithRetryPolicy(),
+	}
+	_, err := computeMgmtClient.DeleteInstanceConfiguration(ctx, req)
+	return err
+}
+
+// 创建实例池
+// https://docs.oracle.com/en-us/iaas/api/#/en/iaas/20160918/InstancePool/CreateInstancePool
+func createInstancePool(details core.CreateInstancePoolDetails) (core.InstancePool, error) {
+	req := core.CreateInstancePoolRequest{
+		CreateInstancePoolDetails: details,
+		RequestMetadata:           getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeMgmtClient.CreateInstancePool(ctx, req)
+	return resp.InstancePool, err
+}
+
+// 修改实例池的实例数量
+func updateInstancePoolSize(poolId *string, size int) (core.InstancePool, error) {
+	req := core.UpdateInstancePoolRequest{
+		InstancePoolId:            poolId,
+		UpdateInstancePoolDetails: core.UpdateInstancePoolDetails{Size: common.Int(size)},
+		RequestMetadata:           getCustomRequestMetadataWithRetryPolicy(),
+	}
+	resp, err := computeMgmtClient.UpdateInstancePool(ctx, req)
+	return resp.InstancePool, err
+}
+
+// 从实例池中分离实例, autoTerminate: 分离后终止实例, decrementSize: 减少实例池的实例数量 (否则实例池会创建新实例)
+func detachInstancePoolInstance(poolId, instanceId *string, autoTerminate, decrementSize bool) error {
+	req := core.DetachInstancePoolInstanceRequest{
+		InstancePoolId: poolId,
+		DetachInstancePoolInstanceDetails: core.DetachInstancePoolInstanceDetails{
+			InstanceId:      instanceId,
+			IsAutoTerminate: common.Bool(autoTerminate),
+			IsDecrementSize: common.Bool(decrementSize),
+		},
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	_, err := computeMgmtClient.DetachInstancePoolInstance(ctx, req)
+	return err
+}
+
+// 终止实例池及其中的所有实例
+func terminateInstancePool(poolId *string) error {
+	req := core.TerminateInstancePoolRequest{
+		InstancePoolId:  poolId,
+		RequestMetadata: getCustomRequestMetadataWithRetryPolicy(),
+	}
+	_, err := computeMgmtClient.TerminateInstancePool(ctx, req)
+	return err
+}
+
 func sendMessage(name, text string) (msg Message, err error) {
 	if token != "" && chat_id != "" {
 		data := url.Values{
@@ -2476,6 +8152,75 @@ func getBootVolumeState(state core.BootVolumeLifecycleStateEnum) string {
 	return friendlyState
 }
 
+func getImageState(state core.ImageLifecycleStateEnum) string {
+	var friendlyState string
+	switch state {
+	case core.ImageLifecycleStateProvisioning:
+		friendlyState = "正在预配"
+	case core.ImageLifecycleStateImporting:
+		friendlyState = "正在导入"
+	case core.ImageLifecycleStateAvailable:
+		friendlyState = "可用　　"
+	case core.ImageLifecycleStateExporting:
+		friendlyState = "正在导出"
+	case core.ImageLifecycleStateDisabled:
+		friendlyState = "已禁用　"
+	case core.ImageLifecycleStateDeleted:
+		friendlyState = "已删除　"
+	default:
+		friendlyState = string(state)
+	}
+	return friendlyState
+}
+
+func getInstancePoolState(state core.InstancePoolLifecycleStateEnum) string {
+	var friendlyState string
+	switch state {
+	case core.InstancePoolLifecycleStateProvisioning:
+		friendlyState = "正在预配"
+	case core.InstancePoolLifecycleStateScaling:
+		friendlyState = "正在扩缩"
+	case core.InstancePoolLifecycleStateStarting:
+		friendlyState = "正在启动"
+	case core.InstancePoolLifecycleStateStopping:
+		friendlyState = "正在停止"
+	case core.InstancePoolLifecycleStateStopped:
+		friendlyState = "已停止　"
+	case core.InstancePoolLifecycleStateRunning:
+		friendlyState = "正在运行"
+	case core.InstancePoolLifecycleStateTerminating:
+		friendlyState = "正在终止"
+	case core.InstancePoolLifecycleStateTerminated:
+		friendlyState = "已终止　"
+	default:
+		friendlyState = string(state)
+	}
+	return friendlyState
+}
+
+func getPublicIpState(state core.PublicIpLifecycleStateEnum) string {
+	var friendlyState string
+	switch state {
+	case core.PublicIpLifecycleStateProvisioning:
+		friendlyState = "正在预配"
+	case core.PublicIpLifecycleStateAvailable, core.PublicIpLifecycleStateUnassigned:
+		friendlyState = "未分配　"
+	case core.PublicIpLifecycleStateAssigning:
+		friendlyState = "正在分配"
+	case core.PublicIpLifecycleStateAssigned:
+		friendlyState = "已分配　"
+	case core.PublicIpLifecycleStateUnassigning:
+		friendlyState = "正在取消"
+	case core.PublicIpLifecycleStateTerminating:
+		friendlyState = "正在释放"
+	case core.PublicIpLifecycleStateTerminated:
+		friendlyState = "已释放　"
+	default:
+		friendlyState = string(state)
+	}
+	return friendlyState
+}
+
 func fmtDuration(d time.Duration) string {
 	if d.Seconds() < 1 {
 		return "< 1 秒"
@@ -0,0 +1,911 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v54/common"
+	"github.com/oracle/oci-go-sdk/v54/core"
+	"gopkg.in/ini.v1"
+)
+
+func TestNewLaunchPacer(t *testing.T) {
+	instance = Instance{MinTime: 5, MaxTime: 60, RetryStrategy: " Backoff ", ReleaseTimes: "00:05, 12:30,bad,"}
+	defer func() { instance = Instance{} }()
+	p := newLaunchPacer()
+	if p.strategy != "backoff" || p.backoffMaxTime != 300 || p.releaseWindow != 5 {
+		t.Errorf("默认值错误: %+v", p)
+	}
+	if len(p.releaseTimes) != 2 || p.releaseTimes[0] != 5 || p.releaseTimes[1] != 750 {
+		t.Errorf("releaseTimes 解析错误: %v", p.releaseTimes)
+	}
+
+	instance = Instance{}
+	if p = newLaunchPacer(); p.strategy != "random" {
+		t.Errorf("默认策略应为 random, 实际: %s", p.strategy)
+	}
+}
+
+func TestLaunchPacerBackoff(t *testing.T) {
+	tests := []struct {
+		minTime, backoffMaxTime, n int32
+		low, high                  int32
+	}{
+		{minTime: 10, backoffMaxTime: 300, n: 1, low: 5, high: 10},
+		{minTime: 10, backoffMaxTime: 300, n: 3, low: 20, high: 40},
+		{minTime: 10, backoffMaxTime: 300, n: 10, low: 150, high: 300},
+		{minTime: 0, backoffMaxTime: 300, n: 1, low: 1, high: 1},
+		{minTime: 0, backoffMaxTime: 300, n: 2, low: 1, high: 2},
+	}
+	for _, tt := range tests {
+		p := &launchPacer{minTime: tt.minTime, backoffMaxTime: tt.backoffMaxTime, tooManyRequests: tt.n}
+		for i := 0; i < 100; i++ {
+			if got := p.backoff(); got < tt.low || got > tt.high {
+				t.Errorf("backoff(minTime=%d, n=%d) = %d, 应在 [%d, %d] 之间", tt.minTime, tt.n, got, tt.low, tt.high)
+				break
+			}
+		}
+	}
+}
+
+func TestLaunchPacerInReleaseWindow(t *testing.T) {
+	p := &launchPacer{releaseTimes: []int{750, 1438}, releaseWindow: 5}
+	tests := []struct {
+		clock string
+		want  bool
+	}{
+		{"23:58", true},
+		{"23:53", true},
+		{"23:52", false},
+		{"00:03", true}, // 跨越午夜
+		{"00:04", false},
+		{"12:25", true},
+		{"12:36", false},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse("15:04", tt.clock)
+		if got := p.inReleaseWindow(now); got != tt.want {
+			t.Errorf("inReleaseWindow(%s) = %v, want %v", tt.clock, got, tt.want)
+		}
+	}
+	if (&launchPacer{releaseWindow: 5}).inReleaseWindow(time.Now()) {
+		t.Error("没有 releaseTimes 时不应在释放窗口内")
+	}
+}
+
+// 返回固定错误响应的 HTTP 客户端, 用于构造 OCI SDK 的 ServiceError
+type errorDispatcher struct {
+	status int
+	body   []byte
+}
+
+func (d errorDispatcher) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: d.status, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(d.body)), Request: req}, nil
+}
+
+type nopSigner struct{}
+
+func (nopSigner) Sign(*http.Request) error { return nil }
+
+func newServiceError(t *testing.T, status int, code, message string) error {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"code": code, "message": message})
+	client := common.BaseClient{HTTPClient: errorDispatcher{status, body}, Signer: nopSigner{}, Host: "localhost", UserAgent: "oci-help-test"}
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	_, err := client.Call(context.Background(), req)
+	if _, ok := common.IsServiceError(err); !ok {
+		t.Fatalf("构造 ServiceError 失败: %v", err)
+	}
+	return err
+}
+
+func TestLoadErrorPolicy(t *testing.T) {
+	cfg, err := ini.Load([]byte(`
+[ERRORPOLICY]
+limit=400|LimitExceeded||abort_template+notify
+capacity=500|InternalError|Out of host capacity|retry
+auth=401-404|||abort_account+notify
+any=*|||notify
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadErrorPolicy(cfg.Section("ERRORPOLICY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []errorPolicyRule{
+		{name: "limit", minStatus: 400, maxStatus: 400, code: "LimitExceeded", action: errorActionAbortTemplate, notify: true},
+		{name: "capacity", minStatus: 500, maxStatus: 500, code: "InternalError", action: errorActionRetry},
+		{name: "auth", minStatus: 401, maxStatus: 404, action: errorActionAbortAccount, notify: true},
+		{name: "any", action: errorActionRetry, notify: true},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("规则数量 = %d, want %d", len(rules), len(want))
+	}
+	for i, rule := range rules {
+		if (rule.message != nil) != (i == 1) {
+			t.Errorf("规则 %s 错误信息正则错误: %v", rule.name, rule.message)
+		}
+		rule.message = nil
+		if rule != want[i] {
+			t.Errorf("规则 %d = %+v, want %+v", i, rule, want[i])
+		}
+	}
+
+	for _, value := range []string{
+		"400|LimitExceeded|abort_template",
+		"4xx|||retry",
+		"400-4xx|||retry",
+		"400||(|retry",
+		"400|||stop",
+	} {
+		cfg := ini.Empty()
+		cfg.Section("ERRORPOLICY").Key("bad").SetValue(value)
+		if _, err := loadErrorPolicy(cfg.Section("ERRORPOLICY")); err == nil {
+			t.Errorf("规则 %q 应返回错误", value)
+		}
+	}
+}
+
+func TestMatchErrorPolicy(t *testing.T) {
+	cfg, _ := ini.Load([]byte(`
+[ERRORPOLICY]
+limit=400|LimitExceeded||abort_template+notify
+capacity=500||out of host capacity|skip_ad
+`))
+	var err error
+	errorPolicyRules, err = loadErrorPolicy(cfg.Section("ERRORPOLICY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { errorPolicyRules = nil }()
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{newServiceError(t, 400, "LimitExceeded", "Service limit exceeded"), "limit"},
+		{newServiceError(t, 400, "InvalidParameter", "Invalid shape"), "400-405"},
+		{newServiceError(t, 500, "InternalError", "Out of host capacity."), "capacity"},
+		{newServiceError(t, 500, "InternalError", "Internal error"), "default"},
+		{newServiceError(t, 429, "TooManyRequests", "Too many requests"), "TooManyRequests"},
+		{newServiceError(t, 409, "IncorrectState", "Conflict"), "IncorrectState"},
+		{newServiceError(t, 409, "Conflict", "Conflict"), "409"},
+		{errors.New("connection reset"), "default"},
+	}
+	for _, tt := range tests {
+		if got := matchErrorPolicy(tt.err); got.name != tt.want {
+			t.Errorf("matchErrorPolicy(%v) = %s, want %s", tt.err, got.name, tt.want)
+		}
+	}
+}
+
+func TestParseShapeLadder(t *testing.T) {
+	ladder, err := parseShapeLadder(" 4/24, 2/12,,0.5/3 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []shapeRung{{4, 24}, {2, 12}, {0.5, 3}}
+	if len(ladder) != len(want) {
+		t.Fatalf("parseShapeLadder = %v, want %v", ladder, want)
+	}
+	for i := range want {
+		if ladder[i] != want[i] {
+			t.Errorf("档位 %d = %v, want %v", i, ladder[i], want[i])
+		}
+	}
+
+	for _, str := range []string{"", " , ", "4", "4/24/1", "a/24", "4/b", "0/6", "1/-6"} {
+		if _, err := parseShapeLadder(str); err == nil {
+			t.Errorf("parseShapeLadder(%q) 应返回错误", str)
+		}
+	}
+}
+
+func TestNextShapeRung(t *testing.T) {
+	ladder := []shapeRung{{4, 24}, {2, 12}, {1, 6}}
+	tests := []struct {
+		name            string
+		index           int
+		usage           freeTierUsage
+		checkA1FreeTier bool
+		want            int
+		ok              bool
+	}{
+		{name: "第一档", index: -1, want: 0, ok: true},
+		{name: "下一档", index: 0, want: 1, ok: true},
+		{name: "回到第一档", index: 2, want: 0, ok: true},
+		{name: "不检查限额", index: 2, usage: freeTierUsage{A1Ocpus: 4, A1MemoryInGBs: 24}, want: 0, ok: true},
+		{name: "跳过超出 OCPU 限额的档位", index: 2, usage: freeTierUsage{A1Ocpus: 2}, checkA1FreeTier: true, want: 1, ok: true},
+		{name: "跳过超出内存限额的档位", index: -1, usage: freeTierUsage{A1MemoryInGBs: 18}, checkA1FreeTier: true, want: 2, ok: true},
+		{name: "只剩当前档位", index: 2, usage: freeTierUsage{A1Ocpus: 3}, checkA1FreeTier: true, want: 2, ok: true},
+		{name: "没有可用档位", index: 0, usage: freeTierUsage{A1Ocpus: 4}, checkA1FreeTier: true, want: 0, ok: false},
+	}
+	for _, tt := range tests {
+		index := tt.index
+		ok := nextShapeRung(ladder, &index, tt.usage, tt.checkA1FreeTier)
+		if ok != tt.ok || index != tt.want {
+			t.Errorf("%s: nextShapeRung = %v, index %d, want %v, index %d", tt.name, ok, index, tt.ok, tt.want)
+		}
+	}
+}
+
+func TestUseShapeRung(t *testing.T) {
+	config := &core.LaunchInstanceShapeConfigDetails{Ocpus: common.Float32(4), MemoryInGBs: common.Float32(24)}
+	request := &core.LaunchInstanceRequest{LaunchInstanceDetails: core.LaunchInstanceDetails{ShapeConfig: config}}
+	shape := &core.Shape{Ocpus: config.Ocpus, MemoryInGBs: config.MemoryInGBs}
+
+	useShapeRung(request, shape, shapeRung{2, 12})
+	if *shape.Ocpus != 2 || *shape.MemoryInGBs != 12 {
+		t.Errorf("shape = %g/%g, want 2/12", *shape.Ocpus, *shape.MemoryInGBs)
+	}
+	if *request.ShapeConfig.Ocpus != 2 || *request.ShapeConfig.MemoryInGBs != 12 {
+		t.Errorf("ShapeConfig = %g/%g, want 2/12", *request.ShapeConfig.Ocpus, *request.ShapeConfig.MemoryInGBs)
+	}
+	if *config.Ocpus != 4 || *config.MemoryInGBs != 24 {
+		t.Error("useShapeRung 不应修改原 ShapeConfig")
+	}
+
+	useShapeRung(nil, shape, shapeRung{1, 6})
+	if *shape.Ocpus != 1 || *shape.MemoryInGBs != 6 {
+		t.Errorf("shape = %g/%g, want 1/6", *shape.Ocpus, *shape.MemoryInGBs)
+	}
+}
+
+func TestFreeTierUsageExceeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		usage  freeTierUsage
+		shape  string
+		ocpus  float32
+		memory float32
+		disk   int64
+		count  int32
+		want   []string
+	}{
+		{name: "A1 未超出", shape: "VM.Standard.A1.Flex", ocpus: 2, memory: 12, disk: 50, count: 2},
+		{name: "A1 刚好用完", usage: freeTierUsage{A1Ocpus: 2, A1MemoryInGBs: 12, StorageInGBs: 100}, shape: "VM.Standard.A1.Flex", ocpus: 2, memory: 12, disk: 100, count: 1},
+		{
+			name: "A1 超出", usage: freeTierUsage{A1Ocpus: 3, A1MemoryInGBs: 18}, shape: "VM.Standard.A1.Flex", ocpus: 1, memory: 8, disk: 50, count: 1,
+			want: []string{"A1 内存(GB): 26 > 24"},
+		},
+		{
+			name: "Micro 个数和存储超出", usage: freeTierUsage{MicroCount: 1, StorageInGBs: 150}, shape: "VM.Standard.E2.1.Micro", disk: 50, count: 2,
+			want: []string{"E2.1.Micro 实例个数: 3 > 2", "块存储(GB): 250 > 200"},
+		},
+		{
+			name: "非 Always Free 实例", usage: freeTierUsage{A1Ocpus: 4, A1MemoryInGBs: 24}, shape: "VM.Standard.E4.Flex", ocpus: 1, memory: 16, disk: 50, count: 1,
+			want: []string{"VM.Standard.E4.Flex 不是 Always Free 实例配置"},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.usage.exceeded(tt.shape, tt.ocpus, tt.memory, tt.disk, tt.count)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: exceeded = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	// 2024-01-01 是星期一
+	base := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2024-01-01 10:08"},
+		{"*/15 * * * *", "2024-01-01 10:15"},
+		{"0 9-17/4 * * *", "2024-01-01 13:00"},
+		{"30 2 * * 1-5", "2024-01-02 02:30"},
+		{"0 0 * * 7", "2024-01-07 00:00"}, // 7 表示星期日
+		{"0 0 * * 0", "2024-01-07 00:00"},
+		{"0 0 15 * 5", "2024-01-05 00:00"}, // 日和周满足其中一个即可
+		{"0 0 * 3 *", "2024-03-01 00:00"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		{"0 0 1,15 * *", "2024-01-15 00:00"},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.next(base).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("parseCron(%q).next = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"0 0 30 2 *", // 永远不会匹配
+		"0 0 31 4,6,9,11 *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) 应返回错误", expr)
+		}
+	}
+}
+
+func TestLaunchSchedule(t *testing.T) {
+	s, err := newLaunchSchedule("22:00-02:00, 12:00-12:30", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clock string
+		want  bool
+	}{
+		{"21:59", false},
+		{"22:00", true},
+		{"00:30", true}, // 跨越零点
+		{"01:59", true},
+		{"02:00", false},
+		{"12:15", true},
+		{"12:30", false},
+	}
+	for _, tt := range tests {
+		now, _ := time.Parse("15:04", tt.clock)
+		if got := s.allowed(now); got != tt.want {
+			t.Errorf("allowed(%s) = %v, want %v", tt.clock, got, tt.want)
+		}
+	}
+	now := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	if got := s.next(now).Format("15:04"); got != "12:00" {
+		t.Errorf("next = %s, want 12:00", got)
+	}
+
+	// 同时满足时间段和 cron 表达式
+	s, err = newLaunchSchedule("09:00-18:00", "*/30 * * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-01-06 是星期六
+	now = time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC)
+	if s.allowed(now) {
+		t.Error("星期六不应在创建时间段内")
+	}
+	if got := s.next(now).Format("2006-01-02 15:04"); got != "2024-01-08 09:00" {
+		t.Errorf("next = %s, want 2024-01-08 09:00", got)
+	}
+	if s.allowed(time.Date(2024, 1, 8, 9, 15, 0, 0, time.UTC)) {
+		t.Error("09:15 不符合 cron 表达式")
+	}
+
+	for _, tt := range [][2]string{
+		{"09:00", ""},
+		{"9-18", ""},
+		{"09:00-09:00", ""},
+		{"09:00-18:00", "0 20 * * *"},
+		{"", "0 0 30 2 *"},
+	} {
+		if _, err := newLaunchSchedule(tt[0], tt[1]); err == nil {
+			t.Errorf("newLaunchSchedule(%q, %q) 应返回错误", tt[0], tt[1])
+		}
+	}
+}
+
+func TestParseReadinessProbes(t *testing.T) {
+	probes, err := parseReadinessProbes(" running, TCP:8080,ssh,ssh:2222,, http://{ip}/health,https://example.com/{ip},Cloud-Init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []readinessProbe{
+		{Kind: "running"},
+		{Kind: "tcp", Target: "8080"},
+		{Kind: "ssh", Target: "22"},
+		{Kind: "ssh", Target: "2222"},
+		{Kind: "http", Target: "http://{ip}/health"},
+		{Kind: "http", Target: "https://example.com/{ip}"},
+		{Kind: "cloud-init"},
+	}
+	if len(probes) != len(want) {
+		t.Fatalf("parseReadinessProbes = %v, want %v", probes, want)
+	}
+	for i := range want {
+		if probes[i] != want[i] {
+			t.Errorf("检查 %d = %+v, want %+v", i, probes[i], want[i])
+		}
+	}
+
+	if probes, err = parseReadinessProbes(""); err != nil || len(probes) != 0 {
+		t.Errorf("空字符串应返回空列表: %v, %v", probes, err)
+	}
+	for _, str := range []string{"tcp", "tcp:", "tcp:0", "tcp:65536", "ssh:abc", "ping", "ftp://{ip}"} {
+		if _, err := parseReadinessProbes(str); err == nil {
+			t.Errorf("parseReadinessProbes(%q) 应返回错误", str)
+		}
+	}
+}
+
+func TestSshUser(t *testing.T) {
+	tests := map[string]string{
+		"Canonical Ubuntu": "ubuntu",
+		"ubuntu":           "ubuntu",
+		"Oracle Linux":     "opc",
+		"CentOS":           "opc",
+		"":                 "opc",
+	}
+	for system, want := range tests {
+		if got := sshUser(system); got != want {
+			t.Errorf("sshUser(%q) = %s, want %s", system, got, want)
+		}
+	}
+}
+
+var testInventoryHosts = []inventoryHost{
+	{Account: "oracle", Region: "ap-tokyo-1", Name: "web 1", InstanceId: "ocid1.instance.aaa", State: "RUNNING", AvailabilityDomain: "AD-1", Shape: "VM.Standard.A1.Flex", OperatingSystem: "Canonical Ubuntu", PublicIp: "192.0.2.1", PrivateIp: "10.0.0.2", User: "ubuntu", Alias: "web-1"},
+	{Account: "oracle", Region: "ap-tokyo-1", Name: "db", InstanceId: "ocid1.instance.bbb", State: "STOPPED", AvailabilityDomain: "AD-2", Shape: "VM.Standard.E2.1.Micro", OperatingSystem: "Oracle Linux", PrivateIp: "10.0.0.3", User: "opc", Alias: "db"},
+	{Account: "oracle", Region: "ap-tokyo-1", Name: "win", InstanceId: "ocid1.instance.ccc", State: "RUNNING", AvailabilityDomain: "AD-1", Shape: "VM.Standard.E2.1.Micro", OperatingSystem: "Windows", PublicIp: "192.0.2.3", User: "opc", Alias: "win"},
+	{Account: "oracle", Region: "ap-tokyo-1", Name: "new", InstanceId: "ocid1.instance.ddd", State: "PROVISIONING", AvailabilityDomain: "AD-1", Shape: "VM.Standard.A1.Flex", User: "opc", Alias: "new"},
+}
+
+func TestAnsibleInventory(t *testing.T) {
+	if got := ansibleGroupName("shape", "VM.Standard.A1.Flex"); got != "shape_VM_Standard_A1_Flex" {
+		t.Errorf("ansibleGroupName = %s", got)
+	}
+
+	want := `[account_oracle]
+web-1 ansible_host=192.0.2.1 ansible_user=ubuntu
+db ansible_host=10.0.0.3 ansible_user=opc
+win ansible_host=192.0.2.3 ansible_user=opc
+
+[region_ap_tokyo_1]
+web-1 ansible_host=192.0.2.1 ansible_user=ubuntu
+db ansible_host=10.0.0.3 ansible_user=opc
+win ansible_host=192.0.2.3 ansible_user=opc
+
+[shape_VM_Standard_A1_Flex]
+web-1 ansible_host=192.0.2.1 ansible_user=ubuntu
+
+[shape_VM_Standard_E2_1_Micro]
+db ansible_host=10.0.0.3 ansible_user=opc
+win ansible_host=192.0.2.3 ansible_user=opc
+
+`
+	if got := string(ansibleIniInventory(testInventoryHosts)); got != want {
+		t.Errorf("ansibleIniInventory:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `all:
+  children:
+    account_oracle:
+      hosts:
+        "web-1":
+          ansible_host: 192.0.2.1
+          ansible_user: ubuntu
+        "db":
+          ansible_host: 10.0.0.3
+          ansible_user: opc
+        "win":
+          ansible_host: 192.0.2.3
+          ansible_user: opc
+    region_ap_tokyo_1:
+      hosts:
+        "web-1":
+          ansible_host: 192.0.2.1
+          ansible_user: ubuntu
+        "db":
+          ansible_host: 10.0.0.3
+          ansible_user: opc
+        "win":
+          ansible_host: 192.0.2.3
+          ansible_user: opc
+    shape_VM_Standard_A1_Flex:
+      hosts:
+        "web-1":
+          ansible_host: 192.0.2.1
+          ansible_user: ubuntu
+    shape_VM_Standard_E2_1_Micro:
+      hosts:
+        "db":
+          ansible_host: 10.0.0.3
+          ansible_user: opc
+        "win":
+          ansible_host: 192.0.2.3
+          ansible_user: opc
+`
+	if got := string(ansibleYamlInventory(testInventoryHosts)); got != want {
+		t.Errorf("ansibleYamlInventory:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSshConfig(t *testing.T) {
+	sshIdentityFile = "~/.ssh/oci"
+	defer func() { sshIdentityFile = "" }()
+	// 没有公共IP的实例和 Windows 实例不导出
+	want := `# oracle ocid1.instance.aaa
+Host web-1
+    HostName 192.0.2.1
+    User ubuntu
+    IdentityFile ~/.ssh/oci
+
+`
+	if got := string(sshConfig(testInventoryHosts)); got != want {
+		t.Errorf("sshConfig:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCsvInventory(t *testing.T) {
+	data, err := csvInventory(testInventoryHosts[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `account,region,name,instance_id,state,availability_domain,shape,operating_system,public_ip,private_ip,ssh_user
+oracle,ap-tokyo-1,web 1,ocid1.instance.aaa,RUNNING,AD-1,VM.Standard.A1.Flex,Canonical Ubuntu,192.0.2.1,10.0.0.2,ubuntu
+oracle,ap-tokyo-1,db,ocid1.instance.bbb,STOPPED,AD-2,VM.Standard.E2.1.Micro,Oracle Linux,,10.0.0.3,opc
+`
+	if string(data) != want {
+		t.Errorf("csvInventory:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestGetNetworkLayout(t *testing.T) {
+	defer func() { instance, oracleSection = Instance{}, nil }()
+	tests := []struct {
+		name     string
+		instance Instance
+		account  string
+		vcn      string
+		subnet   string
+		err      bool
+	}{
+		{name: "默认值", vcn: "10.0.0.0/16", subnet: "10.0.0.0/24"},
+		{name: "子网默认在第一个 VCN 网段内", instance: Instance{VcnCidr: "172.16.5.0/16, 10.1.0.0/16"}, vcn: "172.16.0.0/16,10.1.0.0/16", subnet: "172.16.0.0/24"},
+		{name: "VCN 网段小于 /24", instance: Instance{VcnCidr: "192.168.1.16/28"}, vcn: "192.168.1.16/28", subnet: "192.168.1.16/28"},
+		{name: "账号配置", account: "vcnCidr=10.8.0.0/16\nsubnetCidr=10.8.1.0/24", vcn: "10.8.0.0/16", subnet: "10.8.1.0/24"},
+		{name: "实例模版优先", instance: Instance{SubnetCidr: "10.8.2.0/24"}, account: "vcnCidr=10.8.0.0/16\nsubnetCidr=10.8.1.0/24", vcn: "10.8.0.0/16", subnet: "10.8.2.0/24"},
+		{name: "VCN 格式错误", instance: Instance{VcnCidr: "10.0.0.0"}, err: true},
+		{name: "VCN 前缀过短", instance: Instance{VcnCidr: "10.0.0.0/8"}, err: true},
+		{name: "VCN 重叠", instance: Instance{VcnCidr: "10.0.0.0/16,10.0.1.0/24"}, err: true},
+		{name: "VCN 为空", instance: Instance{VcnCidr: " , "}, err: true},
+		{name: "子网前缀过长", instance: Instance{SubnetCidr: "10.0.0.0/31"}, err: true},
+		{name: "DNS 标签错误", instance: Instance{VcnDnsLabel: "1vcn"}, err: true},
+		{name: "subnetScope=ad 未设置可用性域", instance: Instance{SubnetScope: "ad"}, err: true},
+		{name: "不支持的 subnetType", instance: Instance{SubnetType: "hybrid"}, err: true},
+	}
+	for _, tt := range tests {
+		instance, oracleSection = tt.instance, nil
+		if tt.account != "" {
+			cfg, err := ini.Load([]byte("[oracle]\n" + tt.account))
+			if err != nil {
+				t.Fatal(err)
+			}
+			oracleSection = cfg.Section("oracle")
+		}
+		layout, err := getNetworkLayout()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: 应返回错误", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if vcn := strings.Join(layout.VcnCidrs, ","); vcn != tt.vcn || layout.SubnetCidr != tt.subnet {
+			t.Errorf("%s: VCN %s, 子网 %s, want VCN %s, 子网 %s", tt.name, vcn, layout.SubnetCidr, tt.vcn, tt.subnet)
+		}
+	}
+
+	instance = Instance{VcnDnsLabel: "None", SubnetType: "Private", SubnetScope: "AD", AvailabilityDomain: "AD-1"}
+	layout, err := getNetworkLayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.VcnDnsLabel != "" || layout.SubnetDnsLabel != "subnetdns" || !layout.Private || !layout.AdSpecific {
+		t.Errorf("网络配置错误: %+v", layout)
+	}
+}
+
+func TestCidrWithin(t *testing.T) {
+	vcnCidrs := []string{"10.0.0.0/16", "172.16.0.0/24"}
+	tests := map[string]bool{
+		"10.0.0.0/24":     true,
+		"10.0.255.0/24":   true,
+		"10.0.0.0/16":     true,
+		"10.0.0.0/15":     false,
+		"10.1.0.0/24":     false,
+		"172.16.0.128/25": true,
+		"172.16.0.0/23":   false,
+		"bad":             false,
+	}
+	for cidr, want := range tests {
+		if got := cidrWithin(cidr, vcnCidrs); got != want {
+			t.Errorf("cidrWithin(%s) = %v, want %v", cidr, got, want)
+		}
+	}
+}
+
+func TestCheckSubnetLayout(t *testing.T) {
+	subnets := []core.Subnet{
+		{DisplayName: common.String("a"), CidrBlock: common.String("10.0.0.0/24"), DnsLabel: common.String("subneta")},
+		{DisplayName: common.String("b"), CidrBlock: common.String("10.0.4.0/22")},
+	}
+	vcnDnsLabel := common.String("vcndns")
+	tests := []struct {
+		subnet, dnsLabel string
+		err              bool
+	}{
+		{subnet: "10.0.1.0/24", dnsLabel: "subnetdns"},
+		{subnet: "10.1.0.0/24", err: true},   // 不在 VCN 内
+		{subnet: "10.0.0.128/25", err: true}, // 与子网 a 重叠
+		{subnet: "10.0.0.0/20", err: true},   // 包含子网 a 和 b
+		{subnet: "10.0.5.0/24", err: true},   // 在子网 b 内
+		{subnet: "10.0.1.0/24", dnsLabel: "SubnetA", err: true},
+	}
+	for _, tt := range tests {
+		layout := networkLayout{SubnetCidr: tt.subnet, SubnetDnsLabel: tt.dnsLabel}
+		if err := checkSubnetLayout([]string{"10.0.0.0/16"}, vcnDnsLabel, subnets, &layout); (err != nil) != tt.err {
+			t.Errorf("checkSubnetLayout(%s, %s) = %v, want error %v", tt.subnet, tt.dnsLabel, err, tt.err)
+		}
+	}
+
+	// VCN 没有 DNS 标签时子网不使用 DNS 标签
+	layout := networkLayout{SubnetCidr: "10.0.1.0/24", SubnetDnsLabel: "subnetdns"}
+	if err := checkSubnetLayout([]string{"10.0.0.0/16"}, nil, subnets, &layout); err != nil || layout.SubnetDnsLabel != "" {
+		t.Errorf("checkSubnetLayout = %v, SubnetDnsLabel %q", err, layout.SubnetDnsLabel)
+	}
+}
+
+func TestParseFirewallRule(t *testing.T) {
+	tests := []struct {
+		value string
+		want  firewallRule
+	}{
+		{"tcp/22|203.0.113.7/24|SSH", firewallRule{Protocol: "tcp", MinPort: 22, MaxPort: 22, IcmpType: -1, IcmpCode: -1, Source: "203.0.113.0/24", Description: "SSH"}},
+		{" UDP/51820-51830 ", firewallRule{Protocol: "udp", MinPort: 51820, MaxPort: 51830, IcmpType: -1, IcmpCode: -1, Source: "0.0.0.0/0"}},
+		{"tcp/*", firewallRule{Protocol: "tcp", IcmpType: -1, IcmpCode: -1, Source: "0.0.0.0/0"}},
+		{"icmp/3:4", firewallRule{Protocol: "icmp", IcmpType: 3, IcmpCode: 4, Source: "0.0.0.0/0"}},
+		{"icmp/8", firewallRule{Protocol: "icmp", IcmpType: 8, IcmpCode: -1, Source: "0.0.0.0/0"}},
+		{"icmpv6||ping", firewallRule{Protocol: "icmpv6", IcmpType: -1, IcmpCode: -1, Source: "0.0.0.0/0", Description: "ping"}},
+		{"all|10.0.0.0/16", firewallRule{Protocol: "all", IcmpType: -1, IcmpCode: -1, Source: "10.0.0.0/16"}},
+		{"tcp/443|::/0", firewallRule{Protocol: "tcp", MinPort: 443, MaxPort: 443, IcmpType: -1, IcmpCode: -1, Source: "::/0"}},
+	}
+	for _, tt := range tests {
+		tt.want.Name = "rule"
+		got, err := parseFirewallRule("rule", tt.value)
+		if err != nil {
+			t.Errorf("parseFirewallRule(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseFirewallRule(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+		// 转换为安全列表规则后再转换回来应得到相同的规则
+		if back := firewallRuleFromIngress(got.ingressSecurityRule()); back.key() != got.key() || back.Description != got.Description {
+			t.Errorf("%q 转换后 = %+v, want %+v", tt.value, back, got)
+		}
+	}
+
+	for _, value := range []string{
+		"tcp/22|0.0.0.0/0|SSH|extra",
+		"gre",
+		"all/22",
+		"tcp/0",
+		"tcp/65536",
+		"tcp/30-20",
+		"udp/a",
+		"icmp/256",
+		"icmp/3:-1",
+		"tcp/22|203.0.113.7",
+	} {
+		if _, err := parseFirewallRule("rule", value); err == nil {
+			t.Errorf("parseFirewallRule(%q) 应返回错误", value)
+		}
+	}
+}
+
+// 转换为网络安全组中已有的规则
+func testSecurityRule(t *testing.T, id, value string) core.SecurityRule {
+	t.Helper()
+	rule, err := parseFirewallRule(id, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := rule.addSecurityRuleDetails()
+	return core.SecurityRule{
+		Id:          common.String(id),
+		Direction:   core.SecurityRuleDirectionIngress,
+		Protocol:    details.Protocol,
+		Source:      details.Source,
+		TcpOptions:  details.TcpOptions,
+		UdpOptions:  details.UdpOptions,
+		IcmpOptions: details.IcmpOptions,
+		Description: details.Description,
+	}
+}
+
+func TestDiffNsgRules(t *testing.T) {
+	var rules []firewallRule
+	for i, value := range []string{"tcp/22|203.0.113.0/24|SSH", "tcp/443", "udp/51820", "icmp/3:4", "tcp/443"} {
+		rule, err := parseFirewallRule(strconv.Itoa(i), value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	existing := []core.SecurityRule{
+		testSecurityRule(t, "ssh", "tcp/22|203.0.113.0/24|旧说明"), // 只有说明不同时保留
+		testSecurityRule(t, "http", "tcp/80"),
+		testSecurityRule(t, "https", "tcp/443"),
+		testSecurityRule(t, "https-dup", "tcp/443"),
+		testSecurityRule(t, "ssh-any", "tcp/22"),
+		testSecurityRule(t, "icmp", "icmp/3"),
+	}
+	removeIds, add := diffNsgRules(existing, rules)
+	if got := strings.Join(removeIds, ","); got != "http,https-dup,ssh-any,icmp" {
+		t.Errorf("removeIds = %s, want http,https-dup,ssh-any,icmp", got)
+	}
+	var added []string
+	for _, r := range add {
+		added = append(added, r.key())
+	}
+	if got := strings.Join(added, ","); got != "udp/51820|0.0.0.0/0,icmp/3:4|0.0.0.0/0" {
+		t.Errorf("add = %s", got)
+	}
+
+	if removeIds, add = diffNsgRules(nil, nil); len(removeIds) != 0 || len(add) != 0 {
+		t.Errorf("没有规则时不应有变化: %v, %v", removeIds, add)
+	}
+}
+
+// 示例消息: 删除 host.example.com 的 A 记录后添加 192.0.2.1 和 192.0.2.2, TTL 300
+const rfc2136UpdateHex = "123428000001000000030000" +
+	"076578616d706c6503636f6d0000060001" +
+	"04686f7374076578616d706c6503636f6d00000100ff000000000000" +
+	"04686f7374076578616d706c6503636f6d00000100010000012c0004c0000201" +
+	"04686f7374076578616d706c6503636f6d00000100010000012c0004c0000202"
+
+// 上面消息使用 update-key (hmac-sha256), 签名时间 1700000000 的 TSIG 记录
+const tsigRecordHex = "0a7570646174652d6b657900" +
+	"00fa00ff00000000003d" +
+	"0b686d61632d73686132353600" +
+	"00006553f100012c0020" +
+	"2145fa4da715e0fb25dfe4f13b2d6e0dc18d25932deb3899acd352c414d227d0" +
+	"123400000000"
+
+const testTsigKey = "update-key.:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestWriteDnsName(t *testing.T) {
+	for _, name := range []string{"host.example.com", "host.example.com."} {
+		var buffer bytes.Buffer
+		writeDnsName(&buffer, name)
+		if got, want := hex.EncodeToString(buffer.Bytes()), "04686f7374076578616d706c6503636f6d00"; got != want {
+			t.Errorf("writeDnsName(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestBuildRfc2136Update(t *testing.T) {
+	msg, err := buildRfc2136Update(0x1234, "example.com", "host.example.com", "A", 300, []string{"192.0.2.1", "192.0.2.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustDecodeHex(t, rfc2136UpdateHex); !bytes.Equal(msg, want) {
+		t.Errorf("UPDATE 消息错误\ngot  %x\nwant %x", msg, want)
+	}
+
+	msg, err = buildRfc2136Update(1, "example.com", "host.example.com", "AAAA", 60, []string{"2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustDecodeHex(t, "001c00010000003c001020010db8000000000000000000000001"); !bytes.HasSuffix(msg, want) {
+		t.Errorf("AAAA 记录错误: %x", msg)
+	}
+
+	// 没有地址时只删除记录集
+	msg, err = buildRfc2136Update(0x1234, "example.com", "host.example.com", "A", 300, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustDecodeHex(t, "123428000001000000010000"+
+		"076578616d706c6503636f6d0000060001"+
+		"04686f7374076578616d706c6503636f6d00000100ff000000000000")
+	if !bytes.Equal(msg, want) {
+		t.Errorf("删除记录消息错误\ngot  %x\nwant %x", msg, want)
+	}
+
+	if _, err = buildRfc2136Update(1, "example.com", "host.example.com", "A", 300, []string{"bad"}); err == nil {
+		t.Error("无效 IP 应返回错误")
+	}
+}
+
+func TestSignTsig(t *testing.T) {
+	msg := mustDecodeHex(t, rfc2136UpdateHex)
+	signed, err := signTsig(msg, testTsigKey, "hmac-sha256", 1700000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustDecodeHex(t, rfc2136UpdateHex+tsigRecordHex)
+	// 附加记录数 +1
+	want[11] = 1
+	if !bytes.Equal(signed, want) {
+		t.Errorf("TSIG 签名错误\ngot  %x\nwant %x", signed, want)
+	}
+	if msg[11] != 0 {
+		t.Error("signTsig 不应修改原消息")
+	}
+
+	if _, err = signTsig(msg, "update-key", "", 0); err == nil {
+		t.Error("tsigKey 格式错误时应返回错误")
+	}
+	if _, err = signTsig(msg, testTsigKey, "hmac-sha384", 0); err == nil {
+		t.Error("不支持的算法应返回错误")
+	}
+}
+
+func TestRfc2136UpdateExchange(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	requests := make(chan []byte, 2)
+	go func() {
+		// 第一个请求返回 NOERROR, 第二个返回 REFUSED
+		for _, rcode := range []byte{0, 5} {
+			buffer := make([]byte, 4096)
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			requests <- buffer[:n]
+			resp := make([]byte, 12)
+			copy(resp, buffer[:2])
+			resp[2] = 0xa8 // QR, UPDATE
+			resp[3] = rcode
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	server := conn.LocalAddr().String()
+	values := []string{"192.0.2.1", "192.0.2.2"}
+	if err = rfc2136Update(server, "example.com", "host.example.com", "A", 300, values, testTsigKey, ""); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	id := binary.BigEndian.Uint16(req)
+	msg, _ := buildRfc2136Update(id, "example.com", "host.example.com", "A", 300, values)
+	if !bytes.HasPrefix(req[12:], msg[12:]) || binary.BigEndian.Uint16(req[10:]) != 1 {
+		t.Errorf("服务器收到的消息错误: %x", req)
+	}
+
+	err = rfc2136Update(server, "example.com", "host.example.com", "A", 300, values, "", "")
+	if err == nil || !strings.Contains(err.Error(), "REFUSED") {
+		t.Errorf("应返回 REFUSED 错误, 实际: %v", err)
+	}
+	if req = <-requests; binary.BigEndian.Uint16(req[10:]) != 0 {
+		t.Errorf("未签名消息不应有附加记录: %x", req)
+	}
+}